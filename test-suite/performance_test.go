@@ -22,13 +22,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
-	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"net/http"
 	"os"
 	"osdk-go-perf/testutils"
 	"path/filepath"
 	controllerruntime "sigs.k8s.io/controller-runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -39,14 +45,29 @@ import (
 )
 
 type Timings struct {
+	// RunID links the timings to the metadata of the same run
+	RunID string `json:"runId"`
+	// TimeForCRsReady is the time until the operator reports every CR ready in its status
+	TimeForCRsReady    int64 `json:"timeForCRsReady"`
 	TimeForPodsRunning int64 `json:"timeForPodsRunning"`
 	TimeForPodsDeleted int64 `json:"timeForPodsDeleted"`
+	// CRLatencies are percentiles over the CRs of the time taken to reach each step of their lifecycle, leaving out
+	// the CRs created or deleted by the soak
+	CRLatencies map[string]testutils.LatencyPercentiles `json:"crLatencies"`
+	// RequestLatencies are percentiles of how long the API server took to answer the requests creating, updating and
+	// deleting CRs
+	RequestLatencies map[string]testutils.LatencyPercentiles `json:"requestLatencies"`
+	// ScaleLatencies are percentiles of the time from a CR being updated to its pods being scaled, up then down
+	ScaleLatencies map[string]testutils.LatencyPercentiles `json:"scaleLatencies"`
 }
 
 const (
 	CRNameInYaml           = "memcached-sample"
 	NumberOfCRToCreate     = 15
 	OperatorDeploymentName = "memcached-operator-controller-manager"
+	BaselineDuration       = 2 * time.Minute
+	SteadyDuration         = time.Minute
+	CooldownDuration       = 2 * time.Minute
 )
 
 var _ = Describe("operator-sdk", func() {
@@ -60,18 +81,50 @@ var _ = Describe("operator-sdk", func() {
 		})
 
 		It("should run correctly in a cluster", func() {
+			arrivalPattern, err := testutils.GetArrivalPattern(NumberOfCRToCreate)
+			Expect(err).NotTo(HaveOccurred())
+			crCount := arrivalPattern.Count
+			updatePattern, err := testutils.GetUpdatePattern(crCount)
+			Expect(err).NotTo(HaveOccurred())
+			coalesceConfig, err := testutils.GetCoalesceConfig()
+			Expect(err).NotTo(HaveOccurred())
+			soakConfig, err := testutils.GetSoakConfig()
+			Expect(err).NotTo(HaveOccurred())
+			namespaceSpread, err := testutils.GetNamespaceSpread(crCount)
+			Expect(err).NotTo(HaveOccurred())
+			operandMode, err := testutils.GetOperandMode(oType)
+			Expect(err).NotTo(HaveOccurred())
+			updateSize, err := testutils.GetUpdateSize(operandMode)
+			Expect(err).NotTo(HaveOccurred())
+			// Waits for every CR grow with the number of CRs beyond the default
+			waitTimeout := func(timeout time.Duration) time.Duration {
+				if crCount <= NumberOfCRToCreate {
+					return timeout
+				}
+				return timeout * time.Duration(crCount) / NumberOfCRToCreate
+			}
+			// The CRs and their operands are listed and watched in a single namespace, or all of them when spread
+			crNamespace := namespaceSpread.ListNamespace()
+			crName := func(i int) string {
+				return fmt.Sprintf("%v%02d", CRNameInYaml, i)
+			}
+			crKey := func(i int) types.NamespacedName {
+				return types.NamespacedName{Namespace: namespaceSpread.NamespaceFor(i), Name: crName(i)}
+			}
+			// The CRs which exist once created, the soak replaces some of them with new ones
+			population := make([]types.NamespacedName, 0, crCount)
+			for i := 0; i < crCount; i++ {
+				population = append(population, crKey(i))
+			}
+
 			// Ansible and Helm defaults to number of logical CPUs usable by the current process
-			// Go defaults to 1
+			// Go defaults to 1 and can't be changed via container flag
 			maxConcurrentReconcile := os.Getenv("MAX_CONCURRENT_RECONCILE")
 			if maxConcurrentReconcile != "" && (oType == testutils.HelmType || oType == testutils.AnsibleType) {
 				By("set max concurrent reconciles")
 				err := tc.JSONPatchDeployment(OperatorDeploymentName, testutils.Namespace,
 					fmt.Sprintf(`[{"op": "add", "path": "/spec/template/spec/containers/1/args/-", "value": "--max-concurrent-reconciles=%s" }]`, maxConcurrentReconcile))
 				Expect(err).NotTo(HaveOccurred())
-			} else if oType == testutils.GoType {
-				maxConcurrentReconcile = "1" // TODO - Get from prometheus metric - Go default is one and can't be changed via container flag
-			} else {
-				maxConcurrentReconcile = "4" // TODO - Get from prometheus metric - Was the default on the server used to test
 			}
 
 			cpuLimit := os.Getenv("CPU_LIMIT")
@@ -102,9 +155,11 @@ var _ = Describe("operator-sdk", func() {
 				isDefaultMemoryLimit = true
 			}
 
-			resultsDir := fmt.Sprintf("%s-%s-%s-%s", strings.Split(oType, "/")[0], maxConcurrentReconcile, memoryLimit, cpuLimit)
-			if isDefaultMemoryLimit && isDefaultCpuLimit {
-				resultsDir = fmt.Sprintf("%s-D", resultsDir)
+			if watchNamespace, ok := namespaceSpread.WatchNamespace(); ok {
+				By(fmt.Sprintf("setting the namespaces watched by the operator to %q", watchNamespace))
+				err := tc.PatchDeployment(OperatorDeploymentName, testutils.Namespace,
+					fmt.Sprintf(`{"spec":{"template": {"spec":{"containers":[{"name":"manager","env":[{"name":"WATCH_NAMESPACE","value":%q,"valueFrom":null}]}]}}}}`, watchNamespace))
+				Expect(err).NotTo(HaveOccurred())
 			}
 
 			By("checking if the Operator project Pod is running")
@@ -141,8 +196,46 @@ var _ = Describe("operator-sdk", func() {
 			}
 			Eventually(verifyControllerUp, 2*time.Minute, time.Second).Should(Succeed())
 
+			By("detecting max concurrent reconciles from the manager metrics")
+			detectedMaxConcurrentReconcile := 0
+			Eventually(func() error {
+				portForward, err := tc.PortForwardPod(controllerPodName, testutils.Namespace, testutils.ManagerMetricsPort)
+				if err != nil {
+					return err
+				}
+				defer portForward.Close()
+
+				families, err := testutils.ScrapeMetrics(context.TODO(), &http.Client{Timeout: 10 * time.Second}, portForward.URL("/metrics"))
+				if err != nil {
+					return err
+				}
+				detectedMaxConcurrentReconcile, err = testutils.MaxConcurrentReconciles(families)
+				return err
+			}, 2*time.Minute, time.Second).Should(Succeed())
+			By(fmt.Sprintf("max concurrent reconciles: %d", detectedMaxConcurrentReconcile))
+			if maxConcurrentReconcile != "" {
+				Expect(strconv.Itoa(detectedMaxConcurrentReconcile)).To(Equal(maxConcurrentReconcile),
+					"operator is running with %d max concurrent reconciles but MAX_CONCURRENT_RECONCILE is %s",
+					detectedMaxConcurrentReconcile, maxConcurrentReconcile)
+			}
+
+			resultsDir := fmt.Sprintf("%s-%d-%s-%s", strings.Split(oType, "/")[0], detectedMaxConcurrentReconcile, memoryLimit, cpuLimit)
+			if isDefaultMemoryLimit && isDefaultCpuLimit {
+				resultsDir = fmt.Sprintf("%s-D", resultsDir)
+			}
+			// Runs with the CRs spread over namespaces or a different watch scope are kept apart for comparison
+			if namespaceSpread.Generated() {
+				resultsDir = fmt.Sprintf("%s-ns%d-%s", resultsDir, namespaceSpread.Count, namespaceSpread.Distribution)
+			}
+			if namespaceSpread.WatchScope != "" {
+				resultsDir = fmt.Sprintf("%s-watch-%s", resultsDir, namespaceSpread.WatchScope)
+			}
+			if operandMode != testutils.OperandMemcached {
+				resultsDir = fmt.Sprintf("%s-operand-%s", resultsDir, operandMode)
+			}
+
 			By("ensuring the created ServiceMonitor for the manager")
-			_, err := tc.Kubectl.Get(
+			_, err = tc.Kubectl.Get(
 				true,
 				"ServiceMonitor",
 				fmt.Sprintf("%s-controller-manager-metrics-monitor", tc.ProjectName))
@@ -157,14 +250,15 @@ var _ = Describe("operator-sdk", func() {
 
 			By("wait until metrics available")
 			restConfig := controllerruntime.GetConfigOrDie()
-			metricsClient, err := metricsv.NewForConfig(restConfig)
+			metricsSourceName := testutils.GetMetricsSourceName()
+			metricsSource, err := testutils.NewMetricsSource(metricsSourceName, restConfig)
 			Expect(err).NotTo(HaveOccurred())
 			Eventually(func() error {
-				podMetricsList, err := metricsClient.MetricsV1beta1().PodMetricses(testutils.Namespace).List(context.TODO(), metav1.ListOptions{})
+				podMetrics, err := metricsSource.PodMetrics(context.TODO(), testutils.SampleTarget{Namespace: testutils.Namespace})
 				if err != nil {
 					return err
 				}
-				if len(podMetricsList.Items) != 1 {
+				if len(podMetrics) != 1 {
 					return errors.New("metrics not available yet")
 				}
 
@@ -172,20 +266,123 @@ var _ = Describe("operator-sdk", func() {
 			}, 3*time.Minute, time.Second).Should(Succeed())
 			By("metrics available from pods")
 
-			// Block to gather baseline metrics for 2 minutes once metrics are available
-			By("gathering baseline cpu and memory metrics")
-			metricsBefore := testutils.GatherMetricsForDuration(metricsClient, 2*time.Minute)
+			By("start gathering cpu and memory metrics")
+			sampleTargets, err := testutils.GetSampleTargets(oType, crNamespace)
+			Expect(err).NotTo(HaveOccurred())
+			timeline := testutils.NewTimeline()
+			sampler := testutils.NewSampler(metricsSource, timeline, sampleTargets)
+			sampler.TrackUsage(testutils.RoleOperator, testutils.ManagerContainerName)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			Expect(sampler.Start(ctx, resultsDir)).To(Succeed())
+			// Marks the streamed samples incomplete if the run fails before they are stopped
+			defer sampler.Close()
+
+			By("start gathering cpu throttling of the manager container")
+			kubeHttpClient, err := rest.HTTPClientFor(restConfig)
+			Expect(err).NotTo(HaveOccurred())
+			throttling := testutils.NewThrottlingCollector(kubeHttpClient, restConfig.Host, timeline,
+				testutils.Namespace, controllerPodName, testutils.ManagerContainerName)
+			Expect(throttling.Start(ctx, resultsDir)).To(Succeed())
+			defer throttling.Close()
+
+			By("start watching the operator for restarts")
+			clientset, err := kubernetes.NewForConfig(restConfig)
+			Expect(err).NotTo(HaveOccurred())
+			restarts := testutils.NewRestartWatcher(clientset, timeline, testutils.Namespace, testutils.OperatorPodLabel)
+			Expect(restarts.Start(ctx, resultsDir)).To(Succeed())
+			defer restarts.Close()
+
+			if namespaceSpread.Generated() {
+				By(fmt.Sprintf("creating %d namespaces for the CRs", namespaceSpread.Count))
+				Expect(testutils.CreateNamespaces(context.TODO(), clientset, namespaceSpread.Namespaces)).To(Succeed())
+				defer func() {
+					Expect(testutils.DeleteNamespaces(context.TODO(), clientset, namespaceSpread.Namespaces)).To(Succeed())
+				}()
+			}
+
+			By("start watching events")
+			eventsNamespace := testutils.GetEventsNamespace()
+			if namespaceSpread.Generated() {
+				eventsNamespace = metav1.NamespaceAll
+			}
+			events := testutils.NewEventWatcher(clientset, timeline, eventsNamespace)
+			Expect(events.Start(ctx, resultsDir)).To(Succeed())
+			defer events.Close()
+
+			By("start tracking the lifecycle of each CR")
+			dynamicClient, err := dynamic.NewForConfig(restConfig)
+			Expect(err).NotTo(HaveOccurred())
+			crResource := schema.GroupVersionResource{
+				Group:    fmt.Sprintf("%s.%s", tc.Group, tc.Domain),
+				Version:  tc.Version,
+				Resource: tc.Resources,
+			}
+			crReady := testutils.GetReadinessPredicate(oType)
+			lifecycles := testutils.NewLifecycleTracker(clientset, dynamicClient, crResource, crReady, timeline, crNamespace)
+			Expect(lifecycles.Start(ctx)).To(Succeed())
+			defer lifecycles.Stop()
+			objectCounter := testutils.NewObjectCounter(lifecycles, timeline)
+			objectCounter.Start(ctx)
+			defer objectCounter.Stop()
+
+			// The ansible manager container runs ansible-runner and ansible-playbook processes next to the operator
+			execManager := func(ctx context.Context, command ...string) (string, error) {
+				return tc.ExecPod(ctx, controllerPodName, testutils.Namespace, testutils.ManagerContainerName, command...)
+			}
+			processes := testutils.NewProcessCollector(execManager, timeline)
+			if oType == testutils.AnsibleType {
+				By("start gathering cpu and memory of each process in the manager container")
+				Expect(processes.Start(ctx, resultsDir)).To(Succeed())
+			}
+			defer processes.Close()
+			ansibleArtifacts := testutils.NewAnsibleArtifactCollector(execManager, timeline)
+			collectAnsibleArtifacts := func(label string) {
+				if oType != testutils.AnsibleType {
+					return
+				}
+				By(fmt.Sprintf("collecting %s ansible-runner artifacts", label))
+				Expect(ansibleArtifacts.Collect(context.TODO(), resultsDir, label)).To(Succeed())
+			}
+
+			// The helm operator stores the history of each CR's release in Secrets, snapshotted at the end of each phase
+			var helmReleases []*testutils.HelmReleaseSnapshot
+			snapshotHelmReleases := func() {
+				if oType != testutils.HelmType {
+					return
+				}
+				phase, _ := timeline.Current()
+				By(fmt.Sprintf("snapshotting helm release secrets at the end of the %s phase", phase))
+				snapshot, err := testutils.SnapshotHelmReleases(context.TODO(), clientset, timeline, crNamespace)
+				Expect(err).NotTo(HaveOccurred())
+				helmReleases = append(helmReleases, snapshot)
+			}
 
-			// Unblocking call to gather metrics during creation / processing of CRs
-			metricsChannel := make(chan []v1beta1.PodMetrics, 2)
-			go func(chan []v1beta1.PodMetrics) {
-				By("start gathering metrics for load creation")
-				testutils.GatherMetricsToChannel(metricsClient, 3*time.Minute, metricsChannel)
-			}(metricsChannel)
+			// Only the go/v3 manager is built with the pprof endpoints, blocks while the cpu profile is recorded
+			saveProfiles := func(label string) {
+				if oType != testutils.GoType {
+					return
+				}
+				By(fmt.Sprintf("capturing %s heap and cpu profiles of the manager", label))
+				portForward, err := tc.PortForwardPod(controllerPodName, testutils.Namespace, testutils.ManagerMetricsPort)
+				Expect(err).NotTo(HaveOccurred())
+				defer portForward.Close()
+
+				Expect(testutils.SaveProfiles(context.TODO(), &http.Client{Timeout: testutils.ProfileDuration + time.Minute},
+					portForward.URL(""), resultsDir, timeline.RunID(), label)).To(Succeed())
+			}
+
+			By("gathering baseline cpu and memory metrics")
+			baselineStart := time.Now()
+			time.Sleep(BaselineDuration - testutils.ProfileDuration)
+			saveProfiles("baseline")
+			time.Sleep(BaselineDuration - time.Since(baselineStart))
+			snapshotHelmReleases()
+			timeline.Mark(testutils.PhaseCreate)
 
 			By("creating CR instances")
-			// currently controller-runtime doesn't provide a readiness probe, we retry a few times
-			// we can change it to probe the readiness endpoint after CR supports it.
+			// CRs are created through the API rather than kubectl so process start up is not part of the timings.
+			// Requests are retried as the operator's webhooks may not be ready yet
 			sampleFile := filepath.Join("config", "samples",
 				fmt.Sprintf("%s_%s_%s.yaml", tc.Group, tc.Version, strings.ToLower(tc.Kind)))
 
@@ -194,137 +391,372 @@ var _ = Describe("operator-sdk", func() {
 				err = kbutil.ReplaceInFile(fmt.Sprintf("%s/%s", tc.Dir, sampleFile), "3", "1")
 				Expect(err).NotTo(HaveOccurred())
 			}
-
-			timeBeforeCreatingCR := time.Now()
-			for i := 0; i < NumberOfCRToCreate; i++ {
-				initialName := CRNameInYaml
-				if i > 0 {
-					initialName = fmt.Sprintf("%v%02d", CRNameInYaml, i-1)
-				}
-				err = kbutil.ReplaceInFile(fmt.Sprintf("%s/%s", tc.Dir, sampleFile),
-					initialName,
-					fmt.Sprintf("%v%02d", CRNameInYaml, i),
-				)
-
+			sample, err := testutils.LoadSample(filepath.Join(tc.Dir, sampleFile))
+			Expect(err).NotTo(HaveOccurred())
+			if operandMode == testutils.OperandZero {
+				By("setting the size of every CR to 0 so no operand pods are run")
+				sample, err = testutils.WithSize(sample, oType, 0)
 				Expect(err).NotTo(HaveOccurred())
-
-				Eventually(func() error {
-					_, err = tc.Kubectl.Apply(true, "-f", sampleFile)
-					return err
-				}, time.Minute, time.Second).Should(Succeed())
 			}
+			sampleSize, err := testutils.SizeOf(sample, oType)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(testutils.CheckOperandPods(operandMode, crCount, coalesceConfig,
+				append(soakConfig.Sizes(sampleSize), sampleSize, updateSize)...)).To(Succeed())
+			workload := testutils.NewWorkloadDriver(dynamicClient, crResource, timeline, lifecycles)
+			operandLabel := testutils.OperandPodLabel(oType)
 
-			By("measuring time for all pods to be running")
-			getPodStatus := func() error {
-				var status string
-				// Helm has different labels
-				if oType == testutils.HelmType {
-					status, err = tc.Kubectl.Get(true, "pods", "-l", "app.kubernetes.io/name=memcached", "-o", "jsonpath={.items[*].status.phase}")
-				} else {
-					status, err = tc.Kubectl.Get(true, "pods", "-l", "app=memcached", "-o", "jsonpath={.items[*].status.phase}")
+			By(fmt.Sprintf("sending %d CRs with %s arrivals", crCount, arrivalPattern.Model))
+			timeBeforeCreatingCR := time.Now()
+			arrivals, err := testutils.GenerateArrivals(context.TODO(), arrivalPattern, func(ctx context.Context, i int) error {
+				return workload.Create(ctx, testutils.NamedCR(sample, crName(i), namespaceSpread.NamespaceFor(i)))
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/arrivals", resultsDir), arrivals)).To(Succeed())
+
+			By("measuring time for all CRs to be ready and all pods to be running")
+			// The operator can report a CR done before or after its pods run, so both are checked until both are true
+			var timeForCRsReady, timeForPodsRunning int64
+			Eventually(func() error {
+				if timeForCRsReady == 0 && testutils.CRsReady(context.TODO(), dynamicClient, crResource,
+					crNamespace, crCount, crReady) == nil {
+					timeForCRsReady = time.Now().Sub(timeBeforeCreatingCR).Milliseconds()
 				}
-				if err == nil && strings.TrimSpace(status) == "" {
-					return errors.New("empty status, continue")
+				if timeForPodsRunning == 0 && testutils.PodsRunning(context.TODO(), clientset, crNamespace,
+					operandLabel, crCount*int(sampleSize)) == nil {
+					timeForPodsRunning = time.Now().Sub(timeBeforeCreatingCR).Milliseconds()
 				}
-				nodes := strings.Split(status, " ")
-
-				for i := 0; i < len(nodes); i++ {
-					if nodes[i] != "Running" {
-						return errors.New("not all pods are running yet")
-					}
+				if timeForCRsReady == 0 {
+					return errors.New("not all CRs are ready yet")
 				}
-
-				if len(nodes) != NumberOfCRToCreate {
-					return errors.New("not reached the number of pods yet")
+				if timeForPodsRunning == 0 {
+					return errors.New("not all pods are running yet")
 				}
 
 				return nil
-
-			}
-			Eventually(getPodStatus, 15*time.Minute, time.Second).Should(Succeed())
-			timeForPodsRunning := time.Now().Sub(timeBeforeCreatingCR).Milliseconds()
+			}, waitTimeout(15*time.Minute), time.Second).Should(Succeed())
+			By(fmt.Sprintf("time for all CRs to be ready: %d", timeForCRsReady))
 			By(fmt.Sprintf("time for all pods to be running: %d", timeForPodsRunning))
-
-			// Eventually metrics from during the run will be returned
-			Eventually(func() error {
-				if len(metricsChannel) == 0 {
-					return errors.New("waiting for metrics to be available in channel")
+			snapshotHelmReleases()
+			timeline.Mark(testutils.PhaseSteady)
+			steadyStart := time.Now()
+
+			// The CRs spread over generated namespaces are saved from all namespaces. The run ID is added to the list
+			// metadata so invalid runs can be left out of the analysis
+			getAll := func(resource string) (string, error) {
+				var list string
+				var err error
+				if namespaceSpread.Generated() {
+					list, err = tc.Kubectl.Get(false, resource, "--all-namespaces", "-o", "json")
+				} else {
+					list, err = tc.Kubectl.Get(true, resource, "-o", "json")
 				}
-				return nil
-			}, 5*time.Minute, time.Second).Should(Succeed())
+				if err != nil {
+					return "", err
+				}
+				return testutils.WithRunID(list, timeline.RunID())
+			}
 
 			By("save all CRs in operator namespace")
-			status, err := tc.Kubectl.Get(true, "memcacheds", "-o", "json")
+			status, err := getAll("memcacheds")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/memcacheds", resultsDir), status)).To(Succeed())
 
 			By("save all pods in operator namespace")
-			status, err = tc.Kubectl.Get(true, "pods", "-o", "json")
+			status, err = getAll("pods")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/pods", resultsDir), status)).To(Succeed())
 
 			if oType == testutils.HelmType {
 				By("save all statefulsets in operator namespace for helm type")
-				status, err = tc.Kubectl.Get(true, "statefulsets", "-o", "json")
+				status, err = getAll("statefulsets")
 				Expect(err).NotTo(HaveOccurred())
 				Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/statefulsets", resultsDir), status)).To(Succeed())
 			}
 
 			By("save all deployments in operator namespace")
-			status, err = tc.Kubectl.Get(true, "deployments", "-o", "json")
+			status, err = getAll("deployments")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/deployments", resultsDir), status)).To(Succeed())
 
-			go func(chan []v1beta1.PodMetrics) {
-				By("start gathering metrics for before deleting CRs")
-				testutils.GatherMetricsToChannel(metricsClient, 5*time.Minute, metricsChannel)
-			}(metricsChannel)
+			saveProfiles("peak")
+
+			By("gathering steady state cpu and memory metrics")
+			time.Sleep(SteadyDuration - time.Since(steadyStart))
+
+			collectAnsibleArtifacts("steady")
+
+			if updateSize > 0 {
+				By("updating the size of every CR")
+				snapshotHelmReleases()
+				timeline.Mark(testutils.PhaseUpdate)
+				scale := func(label string, size int64) {
+					By(fmt.Sprintf("scaling every CR %s to %d", label, size))
+					_, err := testutils.GenerateArrivals(context.TODO(), updatePattern, func(ctx context.Context, i int) error {
+						cr, err := testutils.WithSize(testutils.NamedCR(sample, crName(i), namespaceSpread.NamespaceFor(i)), oType, size)
+						if err != nil {
+							return err
+						}
+						lifecycles.ScaleRequested(crKey(i), label, int32(size), time.Now())
+						return workload.Apply(ctx, cr)
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Eventually(lifecycles.PendingScales, waitTimeout(15*time.Minute), time.Second).Should(BeZero())
+				}
+				scale(testutils.ScaleUp, updateSize)
+				scale(testutils.ScaleDown, sampleSize)
+				collectAnsibleArtifacts("update")
+			}
+
+			var soakCreated, soakDeleted []types.NamespacedName
+			var soakTrend *testutils.MemoryTrend
+			if soakConfig.Enabled() {
+				By(fmt.Sprintf("soaking %d CRs for %s, churning %v of them every %s",
+					crCount, soakConfig.Duration, soakConfig.Churn, soakConfig.Interval))
+				snapshotHelmReleases()
+				timeline.Mark(testutils.PhaseSoak)
+				_, soakStart := timeline.Current()
+				soak, err := testutils.NewSoak(soakConfig, workload, timeline, sample, oType, population, crKey)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(soak.Run(context.TODO())).To(Succeed())
+				population = soak.Population()
+				soakCreated = soak.Created()
+				soakDeleted = soak.Deleted()
+				collectAnsibleArtifacts("soak")
+
+				By("fitting a trend to the manager memory after the soak warmup")
+				soakMemory := make([]testutils.UsagePoint, 0)
+				for _, point := range sampler.UsageSeries(testutils.RoleOperator, testutils.ManagerContainerName) {
+					if point.Offset >= soakStart.Milliseconds() {
+						soakMemory = append(soakMemory, point)
+					}
+				}
+				Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/soakMemory", resultsDir), soakMemory)).To(Succeed())
+				trend, err := testutils.FitMemoryTrend(soakMemory, (soakStart + soakConfig.Warmup).Milliseconds(),
+					soakConfig.MaxSlope)
+				Expect(err).NotTo(HaveOccurred())
+				By(fmt.Sprintf("manager memory grew by %.0f bytes/hour after the warmup", trend.SlopeBytesPerHour))
+				Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/soak", resultsDir), testutils.SoakSummary{
+					RunID:  timeline.RunID(),
+					Config: soakConfig,
+					Cycles: soak.Cycles(),
+					Trend:  trend,
+				})).To(Succeed())
+				soakTrend = &trend
+			}
 
 			By("deleting CR instances")
+			snapshotHelmReleases()
+			timeline.Mark(testutils.PhaseDelete)
 			timeBeforeDeletion := time.Now()
-			for i := 0; i < NumberOfCRToCreate; i++ {
-				initialName := fmt.Sprintf("%v%02d", CRNameInYaml, i)
-
-				Eventually(func() error {
-					_, err = tc.Kubectl.Delete(true, "memcacheds", initialName)
-					return err
-				}, time.Minute, time.Second).Should(Succeed())
+			for _, key := range population {
+				Expect(workload.Delete(context.TODO(), key.Namespace, key.Name)).To(Succeed())
 			}
 
-			getAllPodStatus := func() error {
-				if oType == testutils.HelmType {
-					status, err = tc.Kubectl.Get(true, "pods", "-l", "app.kubernetes.io/name=memcached", "-o", "jsonpath={.items[*]}")
-				} else {
-					status, err = tc.Kubectl.Get(true, "pods", "-l", "app=memcached", "-o", "jsonpath={.items[*]}")
+			Eventually(func() error {
+				return testutils.PodsGone(context.TODO(), clientset, crNamespace, operandLabel)
+			}, waitTimeout(5*time.Minute), time.Second).Should(Succeed())
+
+			timeForPodsDeleted := time.Now().Sub(timeBeforeDeletion).Milliseconds()
+			By(fmt.Sprintf("time for all pods to be deleted: %d", timeForPodsDeleted))
+
+			By("gathering cooldown cpu and memory metrics")
+			snapshotHelmReleases()
+			timeline.Mark(testutils.PhaseCooldown)
+			cooldownStart := time.Now()
+			saveProfiles("deleted")
+			time.Sleep(CooldownDuration - time.Since(cooldownStart))
+			collectAnsibleArtifacts("cooldown")
+			snapshotHelmReleases()
+
+			if coalesceConfig.Enabled() {
+				By(fmt.Sprintf("sending bursts of %d patches to a single CR", coalesceConfig.Patches))
+				timeline.Mark(testutils.PhaseCoalesce)
+				portForward, err := tc.PortForwardPod(controllerPodName, testutils.Namespace, testutils.ManagerMetricsPort)
+				Expect(err).NotTo(HaveOccurred())
+				defer portForward.Close()
+				metricsClient := &http.Client{Timeout: 10 * time.Second}
+				countReconciles := func(ctx context.Context) (float64, error) {
+					families, err := testutils.ScrapeMetrics(ctx, metricsClient, portForward.URL("/metrics"))
+					if err != nil {
+						return 0, err
+					}
+					return testutils.ReconcileTotal(families)
+				}
+
+				coalesceCR := testutils.NamedCR(sample, testutils.CoalesceCRName, namespaceSpread.NamespaceFor(0))
+				Expect(workload.Apply(context.TODO(), coalesceCR)).To(Succeed())
+				if sampleSize > 0 {
+					Eventually(func() error {
+						return testutils.PodsRunning(context.TODO(), clientset, crNamespace, operandLabel, int(sampleSize))
+					}, 5*time.Minute, time.Second).Should(Succeed())
 				}
-				if err == nil && strings.TrimSpace(status) == "" {
-					return nil
+
+				bursts := make([]testutils.CoalesceBurst, 0, coalesceConfig.Bursts)
+				currentSize := sampleSize
+				for i := 0; i < coalesceConfig.Bursts; i++ {
+					burst, err := testutils.RunCoalesceBurst(context.TODO(), workload, lifecycles, countReconciles,
+						coalesceCR, oType, i, coalesceConfig.Patches, currentSize, 5*time.Minute)
+					Expect(err).NotTo(HaveOccurred())
+					By(fmt.Sprintf("burst %d: %v reconciles, converged in %dms", i, burst.Reconciles, burst.ConvergedMs))
+					bursts = append(bursts, burst)
+					currentSize = burst.FinalSize
 				}
 
-				return errors.New("waiting for pods to be terminated")
+				Expect(workload.Delete(context.TODO(), coalesceCR.GetNamespace(), testutils.CoalesceCRName)).To(Succeed())
+				Eventually(func() error {
+					return testutils.PodsGone(context.TODO(), clientset, crNamespace, operandLabel)
+				}, 5*time.Minute, time.Second).Should(Succeed())
+				// The helm operator uninstalls the release before removing its finalizer, so the CR being gone means the
+				// release Secrets should be too
+				Eventually(func() bool {
+					_, err := dynamicClient.Resource(crResource).Namespace(coalesceCR.GetNamespace()).Get(context.TODO(),
+						testutils.CoalesceCRName, metav1.GetOptions{})
+					return apierrors.IsNotFound(err)
+				}, 5*time.Minute, time.Second).Should(BeTrue())
+				snapshotHelmReleases()
+
+				By("saving the reconciles caused by each burst of patches to file")
+				coalescing := testutils.SummariseCoalescing(coalesceConfig, bursts)
+				coalescing.RunID = timeline.RunID()
+				Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/coalescing", resultsDir), coalescing)).To(Succeed())
 			}
-			Eventually(getAllPodStatus, 5*time.Minute, time.Second).Should(Succeed())
 
-			timeForPodsDeleted := time.Now().Sub(timeBeforeDeletion).Milliseconds()
-			By(fmt.Sprintf("time for all pods to be deleted: %d", timeForPodsDeleted))
+			Expect(sampler.Stop()).To(Succeed())
+			Expect(throttling.Stop()).To(Succeed())
+			Expect(restarts.Stop()).To(Succeed())
+			Expect(events.Stop()).To(Succeed())
+			Expect(processes.Stop()).To(Succeed())
+			objectCounter.Stop()
+			lifecycles.Stop()
+
+			By("saving the usage of the manager against the number of objects it managed to file")
+			capacity := testutils.SummariseCapacity(operandMode, testutils.CapacityPoints(
+				sampler.UsageSeries(testutils.RoleOperator, testutils.ManagerContainerName), objectCounter.Counts()))
+			capacity.RunID = timeline.RunID()
+			if capacity.Memory != nil {
+				By(fmt.Sprintf("manager memory per CR: %.0f bytes", capacity.Memory.PerCR))
+			}
+			Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/capacity", resultsDir), capacity)).To(Succeed())
+
+			By("saving the lifecycle of each CR to file")
+			// The CR patched by the coalescing scenario is not part of the workload
+			crLifecycles := make([]testutils.CRLifecycle, 0, crCount)
+			for _, lifecycle := range lifecycles.Lifecycles() {
+				if lifecycle.Name != testutils.CoalesceCRName {
+					crLifecycles = append(crLifecycles, lifecycle)
+				}
+			}
+			Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/crLifecycles", resultsDir), crLifecycles)).To(Succeed())
+
+			By("saving the time each CR took to scale to file")
+			crScales := lifecycles.Scales()
+			Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/crScales", resultsDir), crScales)).To(Succeed())
+
+			By("saving the requests made to create, update and delete CRs to file")
+			Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/workloadRequests", resultsDir), workload.Requests())).To(Succeed())
 
 			By("saving timings to file")
+			// The CRs churned by the soak were created or deleted under a different load than the rest
+			soakChurned := map[types.NamespacedName]struct{}{}
+			for _, key := range append(soakCreated, soakDeleted...) {
+				soakChurned[key] = struct{}{}
+			}
+			workloadLifecycles := make([]testutils.CRLifecycle, 0, len(crLifecycles))
+			for _, lifecycle := range crLifecycles {
+				if _, ok := soakChurned[types.NamespacedName{Namespace: lifecycle.Namespace, Name: lifecycle.Name}]; !ok {
+					workloadLifecycles = append(workloadLifecycles, lifecycle)
+				}
+			}
 			timings := Timings{
+				RunID:              timeline.RunID(),
+				TimeForCRsReady:    timeForCRsReady,
 				TimeForPodsRunning: timeForPodsRunning,
 				TimeForPodsDeleted: timeForPodsDeleted,
+				CRLatencies:        testutils.CRLatencies(workloadLifecycles),
+				RequestLatencies:   workload.Latencies(),
+				ScaleLatencies:     testutils.ScaleLatencies(crScales),
 			}
 			Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/timings", resultsDir), timings)).To(Succeed())
 
-			// Save all the metrics to file
-			// Eventually metrics from during the run will be returned
-			Eventually(func() error {
-				if len(metricsChannel) == 1 {
-					return errors.New("waiting for metrics to be available in channel")
+			if oType == testutils.AnsibleType {
+				By("saving ansible task timings to file")
+				Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/ansibleTasks", resultsDir), ansibleArtifacts.Summary())).To(Succeed())
+			}
+
+			if oType == testutils.HelmType {
+				By("saving helm release secrets of each phase to file")
+				Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/helmReleases", resultsDir), helmReleases)).To(Succeed())
+			}
+
+			By("saving run metadata to file")
+			metadata := testutils.RunMetadata{
+				Type:                    oType,
+				OSDKVersion:             tc.GetOSDKVersion(),
+				MaxConcurrentReconciles: detectedMaxConcurrentReconcile,
+				CPULimit:                cpuLimit,
+				MemoryLimit:             memoryLimit,
+				MetricsSource:           metricsSourceName,
+				SampleStats:             sampler.Stats(),
+				InvalidReasons:          restarts.InvalidReasons(),
+				RunID:                   timeline.RunID(),
+				Arrivals:                arrivalPattern,
+				Namespaces:              namespaceSpread,
+				OperandMode:             operandMode,
+			}
+			metadata.Valid = len(metadata.InvalidReasons) == 0
+			for _, reason := range metadata.InvalidReasons {
+				By(fmt.Sprintf("run is not valid: %s", reason))
+			}
+			Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/metadata", resultsDir), metadata)).To(Succeed())
+
+			By("saving cpu throttling of each phase to file")
+			Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/cpuThrottlingPhases", resultsDir), throttling.Summary())).To(Succeed())
+
+			By("saving phases to file")
+			Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/phases", resultsDir), timeline.Transitions())).To(Succeed())
+
+			if testutils.AuditLogEnabled() {
+				By("saving the requests made by the operator from the audit log")
+				auditLog, err := tc.GetAuditLog()
+				Expect(err).NotTo(HaveOccurred())
+				auditUser := testutils.ServiceAccountUser(testutils.Namespace, tc.Kubectl.ServiceAccount)
+				auditEvents, auditLines, err := testutils.ParseAuditLog(auditLog, auditUser, timeline.Start(), time.Now())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(testutils.SaveAuditLog(resultsDir, timeline.RunID(), auditLines)).To(Succeed())
+				Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/auditRequests", resultsDir),
+					testutils.SummariseAuditEvents(auditEvents, timeline, auditUser, crCount))).To(Succeed())
+			}
+
+			if os.Getenv("SCRAPE_METRICS") == "true" {
+				By("saving controller-runtime metrics from prometheus")
+				prometheusClient, err := testutils.NewPrometheusClientForConfig(restConfig)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(testutils.SavePrometheusQueries(context.TODO(), prometheusClient, resultsDir,
+					testutils.OperatorPrometheusQueries(testutils.Namespace), timeline.Start(), time.Now())).To(Succeed())
+			}
+
+			if oType == testutils.HelmType {
+				By("checking the helm release secrets of the deleted CRs were garbage collected")
+				crKeys := make([]types.NamespacedName, 0, crCount)
+				for i := 0; i < crCount; i++ {
+					crKeys = append(crKeys, crKey(i))
 				}
-				return nil
-			}, 10*time.Minute, time.Second).Should(Succeed())
-			allMetrics := append(append(metricsBefore, <-metricsChannel...), <-metricsChannel...)
-			Expect(testutils.SaveAsJsonToDir(fmt.Sprintf("%s/cpuMemory", resultsDir), allMetrics)).To(Succeed())
+				crKeys = append(crKeys, soakCreated...)
+				if coalesceConfig.Enabled() {
+					crKeys = append(crKeys, types.NamespacedName{Namespace: namespaceSpread.NamespaceFor(0),
+						Name: testutils.CoalesceCRName})
+				}
+				// The last snapshot is taken once every CR, including the one of the coalescing scenario, is gone
+				Expect(helmReleases[len(helmReleases)-1].Leftover(crKeys)).To(BeEmpty(),
+					"helm release secrets left after their CRs were deleted")
+			}
+
+			// Checked last so the results of a leaking run are still saved
+			if soakTrend != nil {
+				Expect(soakTrend.Leaking).To(BeFalse(),
+					"manager memory grew by %.0f bytes/hour after the soak warmup, more than the %.0f allowed",
+					soakTrend.SlopeBytesPerHour, soakTrend.MaxSlopeBytesPerHour)
+			}
 		})
 	})
 })
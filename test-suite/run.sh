@@ -14,7 +14,9 @@
 # - Description: Operator SDK version to clone
 # - Default: v1.20.0
 # MAX_CONCURRENT_RECONCILE
-# - Description: Set maximum number of concurrent reconciles via --max-concurrent-reconciles flag (only available for GO & Helm )
+# - Description: Set maximum number of concurrent reconciles via --max-concurrent-reconciles flag (only available for Ansible & Helm).
+#   The value used in the results directory name is read from the controller_runtime_max_concurrent_reconciles metric
+#   and the run fails if it does not match this option
 # - Default: as configured by cloned Operator-SDK project
 # CPU_LIMIT
 # - Description: Set CPU limit resource on Operator container in Deployment
@@ -22,10 +24,98 @@
 # MEMORY_LIMIT
 # - Description: Set Memory limit resource on Operator container in Deployment
 # - Default: as configured by cloned Operator-SDK project
+# SAMPLE_TARGETS
+# - Description: Pods to sample metrics for alongside the operator pod, in the format role:namespace:selector separated by semicolons
+# - Default: operand:memcached-operator-system:<memcached pod label>;kube-apiserver:kube-system:component=kube-apiserver;etcd:kube-system:component=etcd
+# METRICS_SOURCE
+# - Description: Source of pod and node metrics. kubelet reads the kubelet summary API through the API server node proxy
+#   and adds RSS, page faults, network and ephemeral storage usage to each sample
+# - Default: metrics-server
+# - Options: metrics-server | kubelet
 # SCRAPE_METRICS
-# - Description: Set to true to deploy instance of prometheus and kube state metrics to scape cluster and operator metrics
+# - Description: Set to true to deploy instance of prometheus and kube state metrics to scape cluster and operator metrics.
+#   The controller-runtime, workqueue, rest client and Go runtime metrics of the operator are saved to the prometheus directory after each run
 # - Default: false
 # - Options: true
+# CLUSTER_EVENTS
+# - Description: Set to true to save the events of all namespaces to the events directory rather than only the operator namespace
+# - Default: false
+# - Options: true
+# AUDIT_LOG
+# - Description: Set to true to create the KIND cluster with an API server audit log of the requests made by the operator's
+#   service account. The requests of each run are saved to the auditLog directory and broken down by phase, verb and resource
+#   in the auditRequests directory
+# - Default: false
+# - Options: true
+# CR_COUNT
+# - Description: Number of Memcached CRs to create in each run
+# - Default: 15
+# ARRIVAL_MODEL
+# - Description: When each CR is sent. burst sends them all straight away from ARRIVAL_WORKERS workers, rate sends
+#   ARRIVAL_RATE CRs a second, ramp raises the rate linearly from ARRIVAL_START_RATE to ARRIVAL_RATE, and poisson sends
+#   them at random intervals averaging ARRIVAL_RATE a second, repeatable with ARRIVAL_SEED
+# - Default: burst
+# - Options: burst | rate | ramp | poisson
+# ARRIVAL_WORKERS
+# - Description: Number of CRs sent at once by burst arrivals
+# - Default: 1
+# ARRIVAL_RATE
+# - Description: CRs sent per second by rate and poisson arrivals, and the rate ramp arrivals end at
+# ARRIVAL_START_RATE
+# - Description: CRs sent per second at the start of ramp arrivals
+# - Default: 0
+# ARRIVAL_SEED
+# - Description: Seed of the random intervals between poisson arrivals
+# - Default: 1
+# UPDATE_SIZE
+# - Description: Size every CR is scaled up to in the update phase before being scaled back down, 0 skips the update phase
+# - Default: 2, or 0 when OPERAND_MODE is pause or zero
+# UPDATE_RATE
+# - Description: CRs updated per second in the update phase
+# - Default: one after another
+# COALESCE_PATCHES
+# - Description: Number of patches in each burst sent to a single CR after the cooldown to count how many reconciles they cause
+# - Default: 0, the coalescing scenario is not run
+# COALESCE_BURSTS
+# - Description: Number of bursts of patches sent by the coalescing scenario
+# - Default: 3
+# SOAK_DURATION
+# - Description: How long to keep churning the CRs after the update phase to detect memory leaks, e.g. 4h
+# - Default: 0, no soak is run
+# SOAK_WARMUP
+# - Description: How long after the soak started memory is left out of the trend
+# - Default: 30m
+# SOAK_INTERVAL
+# - Description: How often a share of the CRs is deleted, created and resized during the soak
+# - Default: 1m
+# SOAK_CHURN
+# - Description: Share of the CRs churned every SOAK_INTERVAL
+# - Default: 0.1
+# SOAK_MAX_SLOPE
+# - Description: Manager memory growth in bytes per hour after the warmup above which the soak fails
+# - Default: 8388608
+# NAMESPACE_COUNT
+# - Description: Number of generated namespaces the CRs are spread over
+# - Default: 0, every CR is created in the operator's namespace
+# NAMESPACE_DISTRIBUTION
+# - Description: How the CRs are spread over the generated namespaces, skewed puts fewer CRs in each later namespace
+# - Default: round-robin
+# - Options: round-robin | skewed
+# WATCH_SCOPE
+# - Description: Set WATCH_NAMESPACE of the operator to the only namespace of the CRs, every namespace of the CRs, or all
+#   namespaces
+# - Default: WATCH_NAMESPACE is left as deployed
+# - Options: single | list | all
+# OPERAND_MODE
+# - Description: How the operands of the CRs are run, pause swaps the memcached image for PAUSE_IMAGE (go/v3 and
+#   ansible) and zero sets the size of every CR to 0 (ansible and helm), so CR_COUNT can be set to thousands of CRs.
+#   The kind node then runs up to 4000 pods, and the run fails if the operand pods of CR_COUNT CRs would not fit.
+#   With zero the soak does not resize CRs and COALESCE_PATCHES cannot be set
+# - Default: memcached
+# - Options: memcached | pause | zero
+# PAUSE_IMAGE
+# - Description: Image the operands run with OPERAND_MODE=pause
+# - Default: registry.k8s.io/pause:3.7
 # DESTROY_CLUSTER
 # - Description: Set to true to destroy KIND cluster at the end of a single run
 # - Default: false
@@ -46,10 +46,19 @@ var _ = BeforeSuite(func() {
 	By("destroying kind cluster")
 	Expect(tc.DeleteKindCluster()).To(Succeed())
 
-	By("creating kind cluster")
-	Expect(tc.CreateKindCluster()).To(Succeed())
+	projectName := "memcached-operator"
+	namespace := fmt.Sprintf("%s-system", projectName)
+	serviceAccount := fmt.Sprintf("%s-controller-manager", projectName)
 
-	var err error
+	By("creating kind cluster")
+	auditUser := ""
+	if testutils.AuditLogEnabled() {
+		By("enabling the audit log of requests made by the operator")
+		auditUser = testutils.ServiceAccountUser(namespace, serviceAccount)
+	}
+	operandMode, err := testutils.GetOperandMode(testutils.GetType())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(tc.CreateKindCluster(auditUser, testutils.KindMaxPodsFor(operandMode))).To(Succeed())
 
 	By("creating a new test context")
 	tc, err = testutils.NewTestContext(testutils.BinaryName, "GO111MODULE=on")
@@ -60,9 +69,9 @@ var _ = BeforeSuite(func() {
 	tc.Version = "v1alpha1"
 	tc.Kind = "Memcached"
 	tc.Resources = "memcacheds"
-	tc.ProjectName = "memcached-operator"
-	tc.Kubectl.Namespace = fmt.Sprintf("%s-system", tc.ProjectName)
-	tc.Kubectl.ServiceAccount = fmt.Sprintf("%s-controller-manager", tc.ProjectName)
+	tc.ProjectName = projectName
+	tc.Kubectl.Namespace = namespace
+	tc.Kubectl.ServiceAccount = serviceAccount
 
 	oskVersion := tc.GetOSDKVersion()
 
@@ -79,6 +88,19 @@ var _ = BeforeSuite(func() {
 	By("preparing the prerequisites on cluster")
 	tc.InstallPrerequisites()
 
+	if operandMode == testutils.OperandPause {
+		By("running the operands with the pause image")
+		Expect(testutils.UsePauseImage(tc.Dir, oType, testutils.GetPauseImage())).To(Succeed())
+	}
+
+	if oType == testutils.GoType {
+		By("enabling the pprof endpoints of the manager")
+		Expect(testutils.EnablePprof(tc.Dir)).To(Succeed())
+
+		By("limiting the manager's cache to the namespaces in WATCH_NAMESPACE")
+		Expect(testutils.EnableWatchNamespace(tc.Dir)).To(Succeed())
+	}
+
 	By("building the project image")
 	err = tc.Make("docker-build", "IMG="+tc.ImageName)
 	Expect(err).NotTo(HaveOccurred())
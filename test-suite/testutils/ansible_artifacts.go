@@ -0,0 +1,293 @@
+package testutils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AnsibleRunnerDir is where the ansible operator writes the ansible-runner artifacts of each reconcile
+	AnsibleRunnerDir       = "/tmp/ansible-operator/runner"
+	AnsibleArtifactsDir    = "ansibleArtifacts"
+	ansibleEventTimeLayout = "2006-01-02T15:04:05.999999999"
+	// ansibleArtifactsScript archives the runner directory, printing nothing when it does not exist as no CR has
+	// been reconciled yet
+	ansibleArtifactsScript = "[ -d " + AnsibleRunnerDir + " ] || exit 0; tar -cf - -C " + AnsibleRunnerDir + " ."
+)
+
+// AnsibleTaskTiming How long a task ran for on a host in a reconcile
+type AnsibleTaskTiming struct {
+	Task   string `json:"task"`
+	Role   string `json:"role,omitempty"`
+	Action string `json:"action,omitempty"`
+	Host   string `json:"host,omitempty"`
+	// Status is the outcome of the task, one of ok, failed, skipped or unreachable
+	Status          string    `json:"status"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds float64   `json:"durationSeconds"`
+}
+
+// AnsibleReconcile The tasks run by ansible-runner in a single reconcile of a CR
+type AnsibleReconcile struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Ident is the ansible-runner identifier of the reconcile
+	Ident           string              `json:"ident"`
+	Start           time.Time           `json:"start"`
+	End             time.Time           `json:"end"`
+	DurationSeconds float64             `json:"durationSeconds"`
+	Tasks           []AnsibleTaskTiming `json:"tasks"`
+}
+
+// AnsibleTaskSummary How long a task of a role took over every reconcile it ran in
+type AnsibleTaskSummary struct {
+	Task         string  `json:"task"`
+	Role         string  `json:"role,omitempty"`
+	Count        int     `json:"count"`
+	TotalSeconds float64 `json:"totalSeconds"`
+	MeanSeconds  float64 `json:"meanSeconds"`
+	MaxSeconds   float64 `json:"maxSeconds"`
+}
+
+// AnsibleTasks The reconciles found in the runner artifacts and the tasks which took the longest across them
+type AnsibleTasks struct {
+	RunID      string             `json:"runId"`
+	Reconciles []AnsibleReconcile `json:"reconciles"`
+	// Tasks is sorted by the total time spent in each task, longest first
+	Tasks []AnsibleTaskSummary `json:"tasks"`
+}
+
+// ansibleJobEvent The fields of an ansible-runner job event used to time tasks
+type ansibleJobEvent struct {
+	Event     string `json:"event"`
+	Counter   int    `json:"counter"`
+	Created   string `json:"created"`
+	EventData struct {
+		Task       string   `json:"task"`
+		Role       string   `json:"role"`
+		TaskAction string   `json:"task_action"`
+		Host       string   `json:"host"`
+		Start      string   `json:"start"`
+		End        string   `json:"end"`
+		Duration   *float64 `json:"duration"`
+	} `json:"event_data"`
+}
+
+// AnsibleArtifactCollector Copy the ansible-runner artifacts out of the manager container and time the tasks of
+// each reconcile from their job events. The operator only keeps the artifacts of the last few reconciles of each CR,
+// so they are collected more than once during a run and merged
+type AnsibleArtifactCollector struct {
+	exec     PodExecFunc
+	timeline *Timeline
+
+	mu         sync.Mutex
+	reconciles map[string]*AnsibleReconcile
+}
+
+// NewAnsibleArtifactCollector create a collector copying the artifacts with exec
+func NewAnsibleArtifactCollector(exec PodExecFunc, timeline *Timeline) *AnsibleArtifactCollector {
+	return &AnsibleArtifactCollector{
+		exec:       exec,
+		timeline:   timeline,
+		reconciles: map[string]*AnsibleReconcile{},
+	}
+}
+
+// Collect copy the artifacts as a tar archive, saving it gzipped as <runID>-<label>.tar.gz in ansibleArtifacts in
+// the results directory dir, and add the reconciles found in it. Nothing is saved when there are no artifacts yet
+func (a *AnsibleArtifactCollector) Collect(ctx context.Context, dir, label string) error {
+	archive, err := a.exec(ctx, "sh", "-c", ansibleArtifactsScript)
+	if err != nil {
+		return err
+	}
+	if archive == "" {
+		return nil
+	}
+
+	resultsPath, err := MakeResultsDir(fmt.Sprintf("%s/%s", dir, AnsibleArtifactsDir))
+	if err != nil {
+		return err
+	}
+	if err := saveGzipped(fmt.Sprintf("%s/%s-%s.tar.gz", resultsPath, a.timeline.RunID(), label), archive); err != nil {
+		return err
+	}
+
+	return a.Add(strings.NewReader(archive))
+}
+
+// Add parse the job events in a tar archive of the runner directory and keep the reconciles found, replacing a
+// reconcile already seen if it has more tasks, as it was still running when it was seen before
+func (a *AnsibleArtifactCollector) Add(archive io.Reader) error {
+	events := map[string][]ansibleJobEvent{}
+	reconciles := map[string]*AnsibleReconcile{}
+
+	reader := tar.NewReader(archive)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// <group>/<version>/<kind>/<namespace>/<name>/artifacts/<ident>/job_events/<counter>-<uuid>.json
+		parts := strings.Split(path.Clean(header.Name), "/")
+		if header.Typeflag != tar.TypeReg || len(parts) < 6 || parts[len(parts)-2] != "job_events" ||
+			parts[len(parts)-4] != "artifacts" || !strings.HasSuffix(header.Name, ".json") {
+			continue
+		}
+
+		event := ansibleJobEvent{}
+		if err := json.NewDecoder(reader).Decode(&event); err != nil {
+			// Events still being written by a running reconcile can be truncated
+			continue
+		}
+
+		ident := parts[len(parts)-3]
+		if _, ok := reconciles[ident]; !ok {
+			reconciles[ident] = &AnsibleReconcile{
+				Namespace: parts[len(parts)-6],
+				Name:      parts[len(parts)-5],
+				Ident:     ident,
+			}
+		}
+		events[ident] = append(events[ident], event)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for ident, reconcile := range reconciles {
+		timeReconcile(reconcile, events[ident])
+		if seen, ok := a.reconciles[ident]; ok && len(seen.Tasks) >= len(reconcile.Tasks) {
+			continue
+		}
+		a.reconciles[ident] = reconcile
+	}
+
+	return nil
+}
+
+// Summary get the reconciles collected in the order they started and the time spent in each task across them
+func (a *AnsibleArtifactCollector) Summary() AnsibleTasks {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	summary := AnsibleTasks{RunID: a.timeline.RunID(), Reconciles: []AnsibleReconcile{}, Tasks: []AnsibleTaskSummary{}}
+	tasks := map[string]*AnsibleTaskSummary{}
+	for _, reconcile := range a.reconciles {
+		summary.Reconciles = append(summary.Reconciles, *reconcile)
+		for _, task := range reconcile.Tasks {
+			key := fmt.Sprintf("%s/%s", task.Role, task.Task)
+			if _, ok := tasks[key]; !ok {
+				tasks[key] = &AnsibleTaskSummary{Task: task.Task, Role: task.Role}
+			}
+			taskSummary := tasks[key]
+			taskSummary.Count++
+			taskSummary.TotalSeconds += task.DurationSeconds
+			if task.DurationSeconds > taskSummary.MaxSeconds {
+				taskSummary.MaxSeconds = task.DurationSeconds
+			}
+		}
+	}
+	for _, taskSummary := range tasks {
+		taskSummary.MeanSeconds = taskSummary.TotalSeconds / float64(taskSummary.Count)
+		summary.Tasks = append(summary.Tasks, *taskSummary)
+	}
+
+	sort.Slice(summary.Reconciles, func(i, j int) bool {
+		if !summary.Reconciles[i].Start.Equal(summary.Reconciles[j].Start) {
+			return summary.Reconciles[i].Start.Before(summary.Reconciles[j].Start)
+		}
+		return summary.Reconciles[i].Ident < summary.Reconciles[j].Ident
+	})
+	sort.Slice(summary.Tasks, func(i, j int) bool {
+		if summary.Tasks[i].TotalSeconds != summary.Tasks[j].TotalSeconds {
+			return summary.Tasks[i].TotalSeconds > summary.Tasks[j].TotalSeconds
+		}
+		return summary.Tasks[i].Task < summary.Tasks[j].Task
+	})
+
+	return summary
+}
+
+// timeReconcile set the start, end and task timings of a reconcile from its job events
+func timeReconcile(reconcile *AnsibleReconcile, events []ansibleJobEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Counter < events[j].Counter
+	})
+
+	reconcile.Tasks = []AnsibleTaskTiming{}
+	for _, event := range events {
+		if created, err := parseAnsibleTime(event.Created); err == nil {
+			if reconcile.Start.IsZero() || created.Before(reconcile.Start) {
+				reconcile.Start = created
+			}
+			if created.After(reconcile.End) {
+				reconcile.End = created
+			}
+		}
+
+		// Only the result of a task on a host is timed, items of a loop are included in the task's result
+		if !strings.HasPrefix(event.Event, "runner_on_") || event.Event == "runner_on_start" {
+			continue
+		}
+		task := AnsibleTaskTiming{
+			Task:   event.EventData.Task,
+			Role:   event.EventData.Role,
+			Action: event.EventData.TaskAction,
+			Host:   event.EventData.Host,
+			Status: strings.TrimPrefix(event.Event, "runner_on_"),
+		}
+		task.Start, _ = parseAnsibleTime(event.EventData.Start)
+		task.End, _ = parseAnsibleTime(event.EventData.End)
+		if event.EventData.Duration != nil {
+			task.DurationSeconds = *event.EventData.Duration
+		} else if !task.Start.IsZero() && !task.End.IsZero() {
+			task.DurationSeconds = task.End.Sub(task.Start).Seconds()
+		}
+		reconcile.Tasks = append(reconcile.Tasks, task)
+	}
+	reconcile.DurationSeconds = reconcile.End.Sub(reconcile.Start).Seconds()
+}
+
+// parseAnsibleTime parse a time of a job event, which ansible-runner writes in UTC without a timezone
+func parseAnsibleTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("empty time")
+	}
+
+	return time.ParseInLocation(ansibleEventTimeLayout, strings.TrimSuffix(value, "Z"), time.UTC)
+}
+
+// saveGzipped write the data to file compressed with gzip
+func saveGzipped(file, data string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(f)
+	if _, err := io.WriteString(writer, data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
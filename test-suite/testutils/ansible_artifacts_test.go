@@ -0,0 +1,111 @@
+package testutils
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const eventsDir = "./cache.example.com/v1alpha1/Memcached/memcached-operator-system/memcached-sample00/artifacts/123/job_events/"
+
+// fakeRunnerArchive create a tar archive of a runner directory with the given job event files
+func fakeRunnerArchive(events map[string]string) string {
+	buffer := &bytes.Buffer{}
+	writer := tar.NewWriter(buffer)
+	for name, event := range events {
+		Expect(writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(event)), Typeflag: tar.TypeReg})).To(Succeed())
+		_, err := writer.Write([]byte(event))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(writer.Close()).To(Succeed())
+
+	return buffer.String()
+}
+
+var _ = Describe("AnsibleArtifactCollector", func() {
+	var resultsDir string
+
+	BeforeEach(func() {
+		var err error
+		resultsDir, err = os.MkdirTemp("", "results")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Setenv("RESULTS_DIR", resultsDir)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.Unsetenv("RESULTS_DIR")).To(Succeed())
+		Expect(os.RemoveAll(resultsDir)).To(Succeed())
+	})
+
+	It("should time the tasks of each reconcile from the job events", func() {
+		archive := fakeRunnerArchive(map[string]string{
+			eventsDir + "1-a.json": `{"event":"playbook_on_start","counter":1,"created":"2022-08-01T10:00:00.000000"}`,
+			eventsDir + "2-b.json": `{"event":"runner_on_ok","counter":2,"created":"2022-08-01T10:00:01.500000",` +
+				`"event_data":{"task":"start memcached","role":"memcached","task_action":"k8s","host":"localhost",` +
+				`"start":"2022-08-01T10:00:00.500000","end":"2022-08-01T10:00:01.500000","duration":1.0}}`,
+			eventsDir + "3-c.json": `{"event":"runner_on_skipped","counter":3,"created":"2022-08-01T10:00:02.000000",` +
+				`"event_data":{"task":"scale","role":"memcached","start":"2022-08-01T10:00:01.500000",` +
+				`"end":"2022-08-01T10:00:01.750000"}}`,
+			eventsDir + "4-d.json": `{"event":"playbook_on_stats","counter":4,"created":"2022-08-01T10:00:03.000000"}`,
+			eventsDir + "5-e.json": `{"event":"runner_on_ok","counter":5,`,
+			"./cache.example.com/v1alpha1/Memcached/memcached-operator-system/memcached-sample00/artifacts/123/stdout": "ok",
+		})
+		exec := func(_ context.Context, command ...string) (string, error) {
+			Expect(command).To(Equal([]string{"sh", "-c", ansibleArtifactsScript}))
+			return archive, nil
+		}
+		timeline := NewTimeline()
+		collector := NewAnsibleArtifactCollector(exec, timeline)
+		Expect(collector.Collect(context.TODO(), "ansible-test", "steady")).To(Succeed())
+		Expect(filepath.Join(resultsDir, "ansible-test", AnsibleArtifactsDir, timeline.RunID()+"-steady.tar.gz")).To(BeAnExistingFile())
+
+		summary := collector.Summary()
+		Expect(summary.RunID).To(Equal(timeline.RunID()))
+		Expect(summary.Reconciles).To(HaveLen(1))
+		reconcile := summary.Reconciles[0]
+		Expect(reconcile.Name).To(Equal("memcached-sample00"))
+		Expect(reconcile.Namespace).To(Equal(Namespace))
+		Expect(reconcile.Ident).To(Equal("123"))
+		Expect(reconcile.Start).To(Equal(time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)))
+		Expect(reconcile.DurationSeconds).To(Equal(3.0))
+		Expect(reconcile.Tasks).To(HaveLen(2))
+		Expect(reconcile.Tasks[0].Status).To(Equal("ok"))
+		Expect(reconcile.Tasks[1].Status).To(Equal("skipped"))
+		Expect(reconcile.Tasks[1].DurationSeconds).To(Equal(0.25))
+
+		Expect(summary.Tasks).To(Equal([]AnsibleTaskSummary{
+			{Task: "start memcached", Role: "memcached", Count: 1, TotalSeconds: 1, MeanSeconds: 1, MaxSeconds: 1},
+			{Task: "scale", Role: "memcached", Count: 1, TotalSeconds: 0.25, MeanSeconds: 0.25, MaxSeconds: 0.25},
+		}))
+	})
+
+	It("should collect nothing before the runner directory exists", func() {
+		exec := func(_ context.Context, command ...string) (string, error) {
+			return "", nil
+		}
+		collector := NewAnsibleArtifactCollector(exec, NewTimeline())
+		Expect(collector.Collect(context.TODO(), "ansible-test", "baseline")).To(Succeed())
+		Expect(filepath.Join(resultsDir, "ansible-test", AnsibleArtifactsDir)).NotTo(BeADirectory())
+		Expect(collector.Summary().Reconciles).To(BeEmpty())
+	})
+
+	It("should keep the most complete copy of a reconcile", func() {
+		collector := NewAnsibleArtifactCollector(nil, NewTimeline())
+		task := `{"event":"runner_on_ok","counter":2,"event_data":{"task":"start memcached","duration":1.0}}`
+		Expect(collector.Add(bytes.NewBufferString(fakeRunnerArchive(map[string]string{
+			eventsDir + "2-b.json": task,
+			eventsDir + "3-c.json": task,
+		})))).To(Succeed())
+		Expect(collector.Add(bytes.NewBufferString(fakeRunnerArchive(map[string]string{
+			eventsDir + "2-b.json": task,
+		})))).To(Succeed())
+
+		Expect(collector.Summary().Reconciles[0].Tasks).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,267 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// ArrivalBurst sends every CR straight away from a fixed number of workers, each sending its next CR once the
+	// last one was answered
+	ArrivalBurst = "burst"
+	// ArrivalRate, ArrivalRamp and ArrivalPoisson send each CR at a scheduled time whether or not earlier requests
+	// were answered, so a slow API server or operator cannot hold the arrivals back
+	ArrivalRate    = "rate"
+	ArrivalRamp    = "ramp"
+	ArrivalPoisson = "poisson"
+)
+
+// ArrivalPattern How many CRs to create and when to send each of them
+type ArrivalPattern struct {
+	Model string `json:"model"`
+	Count int    `json:"count"`
+	// Workers is the number of CRs sent at once by the burst model
+	Workers int `json:"workers,omitempty"`
+	// Rate is the number of CRs sent per second by the rate and poisson models, and the rate the ramp model ends at
+	Rate float64 `json:"rate,omitempty"`
+	// StartRate is the rate the ramp model starts at
+	StartRate float64 `json:"startRate,omitempty"`
+	// Seed makes the gaps between poisson arrivals repeatable
+	Seed int64 `json:"seed,omitempty"`
+}
+
+// Arrival When a CR was scheduled to be sent and when it was, so a generator which fell behind can be spotted
+type Arrival struct {
+	Index int `json:"index"`
+	// Scheduled and Sent are in milliseconds since the generator started
+	Scheduled int64     `json:"scheduled"`
+	Sent      int64     `json:"sent"`
+	SentAt    time.Time `json:"sentAt"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// GetArrivalPattern get the arrival pattern from the ARRIVAL_MODEL, CR_COUNT, ARRIVAL_WORKERS, ARRIVAL_RATE,
+// ARRIVAL_START_RATE and ARRIVAL_SEED env variables. By default count CRs are sent one after another
+func GetArrivalPattern(count int) (ArrivalPattern, error) {
+	pattern := ArrivalPattern{Model: ArrivalBurst, Count: count, Workers: 1}
+	if model := os.Getenv("ARRIVAL_MODEL"); model != "" {
+		pattern.Model = model
+	}
+
+	var err error
+	if pattern.Count, err = intFromEnv("CR_COUNT", pattern.Count); err != nil {
+		return pattern, err
+	}
+	if pattern.Workers, err = intFromEnv("ARRIVAL_WORKERS", pattern.Workers); err != nil {
+		return pattern, err
+	}
+	if pattern.Rate, err = floatFromEnv("ARRIVAL_RATE", 0); err != nil {
+		return pattern, err
+	}
+	if pattern.StartRate, err = floatFromEnv("ARRIVAL_START_RATE", 0); err != nil {
+		return pattern, err
+	}
+	seed, err := intFromEnv("ARRIVAL_SEED", 1)
+	if err != nil {
+		return pattern, err
+	}
+	pattern.Seed = int64(seed)
+
+	return pattern, pattern.Validate()
+}
+
+// Validate check the pattern has what its model needs
+func (p ArrivalPattern) Validate() error {
+	if p.Count < 1 {
+		return fmt.Errorf("arrival count must be positive, got %d", p.Count)
+	}
+
+	switch p.Model {
+	case ArrivalBurst:
+		if p.Workers < 1 {
+			return fmt.Errorf("burst arrivals need at least 1 worker, got %d", p.Workers)
+		}
+	case ArrivalRate, ArrivalPoisson:
+		if p.Rate <= 0 {
+			return fmt.Errorf("%s arrivals need a positive ARRIVAL_RATE", p.Model)
+		}
+	case ArrivalRamp:
+		if p.Rate <= 0 || p.StartRate < 0 {
+			return fmt.Errorf("ramp arrivals need a positive ARRIVAL_RATE and ARRIVAL_START_RATE of 0 or more")
+		}
+	default:
+		return fmt.Errorf("unknown arrival model %q, expected %s, %s, %s or %s", p.Model,
+			ArrivalBurst, ArrivalRate, ArrivalRamp, ArrivalPoisson)
+	}
+
+	return nil
+}
+
+// Schedule get when each CR is due to be sent, relative to the start of the arrivals
+func (p ArrivalPattern) Schedule() []time.Duration {
+	schedule := make([]time.Duration, p.Count)
+	random := rand.New(rand.NewSource(p.Seed))
+	at := 0.0
+	for i := range schedule {
+		switch p.Model {
+		case ArrivalRate:
+			at = float64(i) / p.Rate
+		case ArrivalPoisson:
+			if i > 0 {
+				at += random.ExpFloat64() / p.Rate
+			}
+		case ArrivalRamp:
+			at = rampArrival(p.StartRate, p.Rate, p.Count, i)
+		}
+		schedule[i] = time.Duration(at * float64(time.Second))
+	}
+
+	return schedule
+}
+
+// Duration get when the last CR is due to be sent
+func (p ArrivalPattern) Duration() time.Duration {
+	schedule := p.Schedule()
+
+	return schedule[len(schedule)-1]
+}
+
+// rampArrival get the seconds at which the ith of count arrivals is due when the rate rises linearly from startRate
+// to endRate over the arrivals
+func rampArrival(startRate, endRate float64, count, i int) float64 {
+	// The ramp lasts as long as it takes to send count CRs at the mean of the two rates, the number sent by t is
+	// startRate*t + slope*t^2/2 which is solved for t
+	duration := 2 * float64(count) / (startRate + endRate)
+	slope := (endRate - startRate) / duration
+	if slope == 0 {
+		return float64(i) / startRate
+	}
+
+	return (-startRate + math.Sqrt(startRate*startRate+2*slope*float64(i))) / slope
+}
+
+// GenerateArrivals call send for each CR following the pattern and record when each was sent. The first error is
+// returned once every CR was sent
+func GenerateArrivals(ctx context.Context, pattern ArrivalPattern, send func(ctx context.Context, i int) error) ([]Arrival, error) {
+	if err := pattern.Validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		arrivals = make([]Arrival, 0, pattern.Count)
+		firstErr error
+	)
+	start := time.Now()
+	arrive := func(i int, scheduled time.Duration) {
+		sentAt := time.Now()
+		err := send(ctx, i)
+
+		mu.Lock()
+		defer mu.Unlock()
+		arrival := Arrival{
+			Index:     i,
+			Scheduled: scheduled.Milliseconds(),
+			Sent:      sentAt.Sub(start).Milliseconds(),
+			SentAt:    sentAt,
+		}
+		if err != nil {
+			arrival.Error = err.Error()
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		arrivals = append(arrivals, arrival)
+	}
+
+	if pattern.Model == ArrivalBurst {
+		indexes := make(chan int, pattern.Count)
+		for i := 0; i < pattern.Count; i++ {
+			indexes <- i
+		}
+		close(indexes)
+		for w := 0; w < pattern.Workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range indexes {
+					if ctx.Err() != nil {
+						return
+					}
+					arrive(i, 0)
+				}
+			}()
+		}
+	} else {
+		for i, scheduled := range pattern.Schedule() {
+			if !sleepContext(ctx, time.Until(start.Add(scheduled))) {
+				break
+			}
+			wg.Add(1)
+			go func(i int, scheduled time.Duration) {
+				defer wg.Done()
+				arrive(i, scheduled)
+			}(i, scheduled)
+		}
+	}
+	wg.Wait()
+
+	sort.Slice(arrivals, func(i, j int) bool {
+		return arrivals[i].Index < arrivals[j].Index
+	})
+	if firstErr == nil && len(arrivals) < pattern.Count {
+		firstErr = fmt.Errorf("sent %d of %d CRs: %v", len(arrivals), pattern.Count, ctx.Err())
+	}
+
+	return arrivals, firstErr
+}
+
+// intFromEnv parse the env variable as an int, using def when it is not set
+func intFromEnv(name string, def int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s %q: %v", name, value, err)
+	}
+
+	return parsed, nil
+}
+
+// floatFromEnv parse the env variable as a float, using def when it is not set
+func floatFromEnv(name string, def float64) (float64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s %q: %v", name, value, err)
+	}
+
+	return parsed, nil
+}
+
+// durationFromEnv parse the env variable as a duration such as 90s or 4h, using def when it is not set
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return def, fmt.Errorf("invalid %s %q: %v", name, value, err)
+	}
+
+	return parsed, nil
+}
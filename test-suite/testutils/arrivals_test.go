@@ -0,0 +1,127 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Arrivals", func() {
+	It("should default to sending the CRs one after another", func() {
+		pattern, err := GetArrivalPattern(15)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pattern).To(Equal(ArrivalPattern{Model: ArrivalBurst, Count: 15, Workers: 1, Seed: 1}))
+	})
+
+	It("should read the pattern from the env", func() {
+		for name, value := range map[string]string{"ARRIVAL_MODEL": "poisson", "CR_COUNT": "100", "ARRIVAL_RATE": "2.5"} {
+			Expect(os.Setenv(name, value)).To(Succeed())
+			defer os.Unsetenv(name)
+		}
+
+		pattern, err := GetArrivalPattern(15)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pattern).To(Equal(ArrivalPattern{Model: ArrivalPoisson, Count: 100, Workers: 1, Rate: 2.5, Seed: 1}))
+
+		Expect(os.Setenv("ARRIVAL_RATE", "")).To(Succeed())
+		_, err = GetArrivalPattern(15)
+		Expect(err).To(MatchError("poisson arrivals need a positive ARRIVAL_RATE"))
+	})
+
+	It("should schedule a fixed rate", func() {
+		schedule := ArrivalPattern{Model: ArrivalRate, Count: 4, Rate: 2}.Schedule()
+		Expect(schedule).To(Equal([]time.Duration{0, 500 * time.Millisecond, time.Second, 1500 * time.Millisecond}))
+	})
+
+	It("should schedule a linear ramp which gets faster", func() {
+		pattern := ArrivalPattern{Model: ArrivalRamp, Count: 100, StartRate: 1, Rate: 9}
+		schedule := pattern.Schedule()
+		Expect(schedule[0]).To(BeZero())
+		// The rate rises during each gap, so the gaps are a little shorter than the rate at their start says
+		Expect(schedule[1] - schedule[0]).To(BeNumerically("~", time.Second, 200*time.Millisecond))
+		Expect(schedule[99] - schedule[98]).To(BeNumerically("~", time.Second/9, 10*time.Millisecond))
+		// 100 CRs at a mean rate of 5 a second take 20 seconds
+		Expect(pattern.Duration()).To(BeNumerically("~", 20*time.Second, 200*time.Millisecond))
+
+		flat := ArrivalPattern{Model: ArrivalRamp, Count: 3, StartRate: 2, Rate: 2}.Schedule()
+		Expect(flat).To(Equal(ArrivalPattern{Model: ArrivalRate, Count: 3, Rate: 2}.Schedule()))
+	})
+
+	It("should schedule repeatable poisson arrivals at the target rate", func() {
+		pattern := ArrivalPattern{Model: ArrivalPoisson, Count: 10000, Rate: 50, Seed: 7}
+		schedule := pattern.Schedule()
+		Expect(schedule).To(Equal(pattern.Schedule()))
+		Expect(pattern.Duration().Seconds()).To(BeNumerically("~", 200, 10))
+		for i := 1; i < len(schedule); i++ {
+			Expect(schedule[i]).To(BeNumerically(">=", schedule[i-1]))
+		}
+	})
+
+	It("should send a burst from the workers", func() {
+		var (
+			mu      sync.Mutex
+			running int
+			peak    int
+		)
+		send := func(ctx context.Context, i int) error {
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+
+			if i == 3 {
+				return errors.New("rejected")
+			}
+			return nil
+		}
+
+		arrivals, err := GenerateArrivals(context.TODO(), ArrivalPattern{Model: ArrivalBurst, Count: 12, Workers: 3}, send)
+		Expect(err).To(MatchError("rejected"))
+		Expect(arrivals).To(HaveLen(12))
+		Expect(peak).To(Equal(3))
+		for i, arrival := range arrivals {
+			Expect(arrival.Index).To(Equal(i))
+			Expect(arrival.Scheduled).To(BeZero())
+		}
+		Expect(arrivals[3].Error).To(Equal("rejected"))
+	})
+
+	It("should send scheduled arrivals without waiting for earlier ones to be answered", func() {
+		send := func(ctx context.Context, i int) error {
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		}
+
+		start := time.Now()
+		arrivals, err := GenerateArrivals(context.TODO(), ArrivalPattern{Model: ArrivalRate, Count: 5, Rate: 100}, send)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically("<", 300*time.Millisecond))
+		for i, arrival := range arrivals {
+			Expect(arrival.Scheduled).To(Equal(int64(i * 10)))
+			Expect(arrival.Sent).To(BeNumerically(">=", arrival.Scheduled))
+		}
+	})
+
+	It("should stop sending once the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		send := func(ctx context.Context, i int) error {
+			cancel()
+			return nil
+		}
+
+		arrivals, err := GenerateArrivals(ctx, ArrivalPattern{Model: ArrivalRate, Count: 5, Rate: 10}, send)
+		Expect(err).To(MatchError(ContainSubstring("sent 1 of 5 CRs")))
+		Expect(arrivals).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,255 @@
+package testutils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// KindConfigPath and AuditPolicyPath are templates relative to the test suite, unlike the manifests applied from
+	// the project directory
+	KindConfigPath  = "templates/kind.yaml"
+	AuditPolicyPath = "templates/audit-policy.yaml"
+	// AuditLogPath is where the API server writes the audit log inside the kind control plane node
+	AuditLogPath = "/var/log/kubernetes/kube-apiserver-audit.log"
+)
+
+// AuditEvent The fields of an API server audit event used to break down the requests of the operator
+type AuditEvent struct {
+	Stage string `json:"stage"`
+	Verb  string `json:"verb"`
+	User  struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	ObjectRef *struct {
+		Resource    string `json:"resource"`
+		Subresource string `json:"subresource"`
+		APIGroup    string `json:"apiGroup"`
+		Namespace   string `json:"namespace"`
+		Name        string `json:"name"`
+	} `json:"objectRef"`
+	ResponseStatus *struct {
+		Code int `json:"code"`
+	} `json:"responseStatus"`
+	RequestReceivedTimestamp metav1.MicroTime `json:"requestReceivedTimestamp"`
+	StageTimestamp           metav1.MicroTime `json:"stageTimestamp"`
+}
+
+// AuditRequestStats The requests made for a verb and resource in a phase and how long the API server took to answer
+type AuditRequestStats struct {
+	Phase    Phase  `json:"phase"`
+	Verb     string `json:"verb"`
+	APIGroup string `json:"apiGroup,omitempty"`
+	// Resource includes the subresource, e.g. memcacheds/status
+	Resource string `json:"resource"`
+	Count    int    `json:"count"`
+	// PerCR is the number of requests divided by the number of CRs created in the run
+	PerCR float64 `json:"perCR"`
+	// Latencies are from the request being received to the response completing, or starting for watches
+	MeanLatencyMs float64 `json:"meanLatencyMs"`
+	P50LatencyMs  float64 `json:"p50LatencyMs"`
+	P99LatencyMs  float64 `json:"p99LatencyMs"`
+	MaxLatencyMs  float64 `json:"maxLatencyMs"`
+	// Errors is the number of requests answered with a status code of 400 or above
+	Errors int `json:"errors"`
+}
+
+// AuditSummary The requests a user made during a run broken down by phase, verb and resource
+type AuditSummary struct {
+	RunID    string              `json:"runId"`
+	User     string              `json:"user"`
+	CRs      int                 `json:"crs"`
+	Total    int                 `json:"total"`
+	Requests []AuditRequestStats `json:"requests"`
+}
+
+// AuditLogEnabled check whether the run should be made against a cluster with audit logging of the operator
+func AuditLogEnabled() bool {
+	return os.Getenv("AUDIT_LOG") == "true"
+}
+
+// ServiceAccountUser get the user name the API server authenticates a service account as
+func ServiceAccountUser(namespace, serviceAccount string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccount)
+}
+
+// WriteKindConfig render the kind cluster config to a new temporary directory and return its path. When user is set
+// the audit policy logging the requests of user is rendered next to it, and when maxPods is set the node runs up to
+// maxPods pods
+func WriteKindConfig(user string, maxPods int) (string, error) {
+	dir, err := os.MkdirTemp("", "kind-config")
+	if err != nil {
+		return "", err
+	}
+
+	values := map[string]interface{}{
+		"User":             user,
+		"PolicyDir":        dir,
+		"LogPath":          AuditLogPath,
+		"MaxPods":          maxPods,
+		"NodeCIDRMaskSize": KindNodeCIDRMaskSize,
+	}
+	files := []string{KindConfigPath}
+	if user != "" {
+		files = append(files, AuditPolicyPath)
+	}
+	for _, file := range files {
+		if err := renderTemplate(file, filepath.Join(dir, filepath.Base(file)), values); err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(dir, filepath.Base(KindConfigPath)), nil
+}
+
+// ParseAuditLog read the events of the audit log made by user between start and end, keeping the lines they were
+// read from. A request is logged once it completes, or once the response starts for a watch
+func ParseAuditLog(log, user string, start, end time.Time) ([]AuditEvent, []string, error) {
+	var (
+		events []AuditEvent
+		lines  []string
+	)
+	scanner := bufio.NewScanner(strings.NewReader(log))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Skip other users before decoding, the log of a reused cluster holds every earlier run
+		if !strings.Contains(line, user) {
+			continue
+		}
+
+		event := AuditEvent{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			continue
+		}
+		received := event.RequestReceivedTimestamp.Time
+		if event.User.Username != user || received.Before(start) || received.After(end) {
+			continue
+		}
+		if (event.Verb == "watch") != (event.Stage == "ResponseStarted") {
+			continue
+		}
+
+		events = append(events, event)
+		lines = append(lines, line)
+	}
+
+	return events, lines, scanner.Err()
+}
+
+// SummariseAuditEvents count the requests and their latencies by the phase of the timeline they were received in,
+// verb and resource
+func SummariseAuditEvents(events []AuditEvent, timeline *Timeline, user string, crs int) AuditSummary {
+	summary := AuditSummary{RunID: timeline.RunID(), User: user, CRs: crs, Total: len(events), Requests: []AuditRequestStats{}}
+
+	groups := map[string]*AuditRequestStats{}
+	latencies := map[string][]float64{}
+	for _, event := range events {
+		stats := AuditRequestStats{Phase: timeline.PhaseAt(event.RequestReceivedTimestamp.Time), Verb: event.Verb}
+		if event.ObjectRef != nil {
+			stats.APIGroup = event.ObjectRef.APIGroup
+			stats.Resource = event.ObjectRef.Resource
+			if event.ObjectRef.Subresource != "" {
+				stats.Resource = fmt.Sprintf("%s/%s", stats.Resource, event.ObjectRef.Subresource)
+			}
+		}
+
+		key := fmt.Sprintf("%s/%s/%s/%s", stats.Phase, stats.Verb, stats.APIGroup, stats.Resource)
+		if _, ok := groups[key]; !ok {
+			groups[key] = &stats
+		}
+		group := groups[key]
+		group.Count++
+		if event.ResponseStatus != nil && event.ResponseStatus.Code >= 400 {
+			group.Errors++
+		}
+		latency := event.StageTimestamp.Sub(event.RequestReceivedTimestamp.Time)
+		latencies[key] = append(latencies[key], float64(latency.Microseconds())/1000)
+	}
+
+	phaseOrder := map[Phase]int{}
+	for i, transition := range timeline.Transitions() {
+		if _, ok := phaseOrder[transition.Phase]; !ok {
+			phaseOrder[transition.Phase] = i
+		}
+	}
+	for key, group := range groups {
+		values := latencies[key]
+		sort.Float64s(values)
+		total := 0.0
+		for _, value := range values {
+			total += value
+		}
+		group.MeanLatencyMs = total / float64(len(values))
+		group.P50LatencyMs = percentile(values, 0.5)
+		group.P99LatencyMs = percentile(values, 0.99)
+		group.MaxLatencyMs = values[len(values)-1]
+		if crs > 0 {
+			group.PerCR = float64(group.Count) / float64(crs)
+		}
+		summary.Requests = append(summary.Requests, *group)
+	}
+	sort.Slice(summary.Requests, func(i, j int) bool {
+		a, b := summary.Requests[i], summary.Requests[j]
+		if a.Phase != b.Phase {
+			return phaseOrder[a.Phase] < phaseOrder[b.Phase]
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Verb < b.Verb
+	})
+
+	return summary
+}
+
+// percentile get the value at quantile q of sorted values using the nearest rank
+func percentile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+
+	return sorted[rank]
+}
+
+// renderTemplate execute the text template in file with values and write it to out
+func renderTemplate(file, out string, values interface{}) error {
+	tmpl, err := template.ParseFiles(file)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(f, values); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// SaveAuditLog save the audit log lines of a run gzipped as <runID>.log.gz in auditLog in the results directory dir
+func SaveAuditLog(dir, runID string, lines []string) error {
+	path, err := MakeResultsDir(fmt.Sprintf("%s/auditLog", dir))
+	if err != nil {
+		return err
+	}
+
+	return saveGzipped(fmt.Sprintf("%s/%s.log.gz", path, runID), strings.Join(lines, "\n")+"\n")
+}
@@ -0,0 +1,125 @@
+package testutils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const operatorUser = "system:serviceaccount:memcached-operator-system:memcached-operator-controller-manager"
+
+// kindConfigPatches parse the kind cluster config at path and get its kubeadm config patches
+func kindConfigPatches(path string) []map[string]interface{} {
+	data, err := os.ReadFile(path)
+	Expect(err).NotTo(HaveOccurred())
+	config := struct {
+		Nodes []struct {
+			KubeadmConfigPatches []string `json:"kubeadmConfigPatches"`
+		} `json:"nodes"`
+	}{}
+	Expect(yaml.Unmarshal(data, &config)).To(Succeed())
+	Expect(config.Nodes).To(HaveLen(1))
+
+	patches := make([]map[string]interface{}, 0, len(config.Nodes[0].KubeadmConfigPatches))
+	for _, patch := range config.Nodes[0].KubeadmConfigPatches {
+		parsed := map[string]interface{}{}
+		Expect(yaml.Unmarshal([]byte(patch), &parsed)).To(Succeed())
+		patches = append(patches, parsed)
+	}
+
+	return patches
+}
+
+// fakeAuditEvent create an audit log line for a request received at received which took latency
+func fakeAuditEvent(user, stage, verb, resource, subresource string, code int, received time.Time, latency time.Duration) string {
+	return fmt.Sprintf(`{"kind":"Event","level":"Metadata","stage":%q,"verb":%q,"user":{"username":%q},`+
+		`"objectRef":{"resource":%q,"subresource":%q,"namespace":"memcached-operator-system","apiGroup":"cache.example.com"},`+
+		`"responseStatus":{"code":%d},"requestReceivedTimestamp":%q,"stageTimestamp":%q}`,
+		stage, verb, user, resource, subresource, code,
+		received.UTC().Format(metav1.RFC3339Micro), received.Add(latency).UTC().Format(metav1.RFC3339Micro))
+}
+
+var _ = Describe("Audit log", func() {
+	It("should render the kind config and audit policy for the user", func() {
+		Expect(os.Chdir("..")).To(Succeed())
+		defer func() {
+			Expect(os.Chdir("testutils")).To(Succeed())
+		}()
+
+		config, err := WriteKindConfig(operatorUser, 0)
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(filepath.Dir(config))
+
+		kindConfig, err := os.ReadFile(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(kindConfig)).To(ContainSubstring("hostPath: " + filepath.Dir(config)))
+		Expect(string(kindConfig)).NotTo(ContainSubstring("max-pods"))
+		patches := kindConfigPatches(config)
+		Expect(patches).To(HaveLen(1))
+		Expect(patches[0]).To(HaveKeyWithValue("kind", "ClusterConfiguration"))
+		Expect(patches[0]).To(HaveKey("apiServer"))
+		policy, err := os.ReadFile(filepath.Join(filepath.Dir(config), "audit-policy.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(policy)).To(ContainSubstring(`users: ["` + operatorUser + `"]`))
+	})
+
+	It("should render a kind config running more pods without the audit log", func() {
+		Expect(os.Chdir("..")).To(Succeed())
+		defer func() {
+			Expect(os.Chdir("testutils")).To(Succeed())
+		}()
+
+		config, err := WriteKindConfig("", KindMaxPods)
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(filepath.Dir(config))
+
+		patches := kindConfigPatches(config)
+		Expect(patches).To(HaveLen(2))
+		Expect(patches[0]).To(HaveKeyWithValue("nodeRegistration", HaveKeyWithValue("kubeletExtraArgs",
+			HaveKeyWithValue("max-pods", fmt.Sprint(KindMaxPods)))))
+		Expect(patches[1]).NotTo(HaveKey("apiServer"))
+		Expect(patches[1]).To(HaveKeyWithValue("controllerManager", HaveKeyWithValue("extraArgs",
+			HaveKeyWithValue("node-cidr-mask-size", fmt.Sprint(KindNodeCIDRMaskSize)))))
+		Expect(filepath.Join(filepath.Dir(config), "audit-policy.yaml")).NotTo(BeAnExistingFile())
+	})
+
+	It("should break down the requests of the user by phase, verb and resource", func() {
+		timeline := NewTimeline()
+		start := timeline.Start()
+		time.Sleep(10 * time.Millisecond)
+		timeline.Mark(PhaseCreate)
+		created := time.Now()
+
+		log := strings.Join([]string{
+			fakeAuditEvent(operatorUser, "ResponseComplete", "list", "memcacheds", "", 200, start.Add(-time.Hour), time.Millisecond),
+			fakeAuditEvent(operatorUser, "ResponseStarted", "watch", "memcacheds", "", 200, start.Add(time.Millisecond), time.Millisecond),
+			fakeAuditEvent(operatorUser, "ResponseComplete", "watch", "memcacheds", "", 200, start.Add(time.Millisecond), time.Hour),
+			fakeAuditEvent(operatorUser, "ResponseComplete", "patch", "memcacheds", "status", 200, created, 4*time.Millisecond),
+			fakeAuditEvent(operatorUser, "ResponseComplete", "patch", "memcacheds", "status", 409, created, 2*time.Millisecond),
+			fakeAuditEvent("system:admin", "ResponseComplete", "get", "memcacheds", "", 200, created, time.Millisecond),
+			`{"truncated`,
+		}, "\n")
+
+		events, lines, err := ParseAuditLog(log, operatorUser, start, created.Add(time.Minute))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(events).To(HaveLen(3))
+		Expect(lines).To(HaveLen(3))
+
+		summary := SummariseAuditEvents(events, timeline, operatorUser, 2)
+		Expect(summary.RunID).To(Equal(timeline.RunID()))
+		Expect(summary.Total).To(Equal(3))
+		Expect(summary.Requests).To(Equal([]AuditRequestStats{
+			{Phase: PhaseBaseline, Verb: "watch", APIGroup: "cache.example.com", Resource: "memcacheds", Count: 1, PerCR: 0.5,
+				MeanLatencyMs: 1, P50LatencyMs: 1, P99LatencyMs: 1, MaxLatencyMs: 1},
+			{Phase: PhaseCreate, Verb: "patch", APIGroup: "cache.example.com", Resource: "memcacheds/status", Count: 2, PerCR: 1,
+				MeanLatencyMs: 3, P50LatencyMs: 2, P99LatencyMs: 4, MaxLatencyMs: 4, Errors: 1},
+		}))
+	})
+})
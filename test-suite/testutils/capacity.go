@@ -0,0 +1,329 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// OperandMemcached runs the memcached operand of the sample. OperandPause swaps the operand image for a pause
+	// image and OperandZero sets the size of every CR to 0, so thousands of CRs can be created without the operand
+	// pods limiting the run
+	OperandMemcached  = "memcached"
+	OperandPause      = "pause"
+	OperandZero       = "zero"
+	DefaultPauseImage = "registry.k8s.io/pause:3.7"
+
+	// KindMaxPods is how many pods the kind node runs when the operands are not memcached, up from the kubelet's 110.
+	// KindNodeCIDRMaskSize gives the node enough pod addresses for them
+	KindMaxPods          = 4000
+	KindNodeCIDRMaskSize = 20
+	// KindReservedPods is room left on the kind node for the pods of the cluster, the operator and monitoring
+	KindReservedPods = 100
+)
+
+var (
+	// memcachedImage matches the operand image in the go controller and the ansible role
+	memcachedImage = regexp.MustCompile(`"(docker\.io/)?memcached:[^"]*"`)
+	// goMemcachedCommand and ansibleMemcachedCommand match the whole operand command including the memcached flags,
+	// which the pause binary does not accept: given -v it prints its version and exits
+	goMemcachedCommand      = regexp.MustCompile(`\[\]string\{"memcached"[^}]*\}`)
+	ansibleMemcachedCommand = regexp.MustCompile(`(?m)^([ \t]*)- memcached[ \t]*\n((?:[ \t]*- .*\n)*)`)
+)
+
+// ObjectCount How many CRs and objects owned by them existed from the offset on
+type ObjectCount struct {
+	// Offset is the number of milliseconds since the start of the run
+	Offset  int64 `json:"offset"`
+	Phase   Phase `json:"phase"`
+	CRs     int   `json:"crs"`
+	Objects int   `json:"objects"`
+}
+
+// CapacityPoint The cpu and memory usage of the manager in a sample with the number of objects it managed at the time
+type CapacityPoint struct {
+	Offset        int64 `json:"offset"`
+	Phase         Phase `json:"phase"`
+	CRs           int   `json:"crs"`
+	Objects       int   `json:"objects"`
+	CPUMillicores int64 `json:"cpuMillicores"`
+	MemoryBytes   int64 `json:"memoryBytes"`
+}
+
+// CapacityFit The least squares line through the usage of the manager against the number of CRs
+type CapacityFit struct {
+	PerCR float64 `json:"perCR"`
+	// Base is the usage the line gives with no CRs
+	Base float64 `json:"base"`
+	R2   float64 `json:"r2"`
+}
+
+// CapacitySummary The usage of the manager against the number of objects it managed over the run. The fits only use
+// the baseline, create and steady phases, while the population grows, as freed memory is not always returned once
+// CRs are deleted
+type CapacitySummary struct {
+	RunID       string          `json:"runId"`
+	OperandMode string          `json:"operandMode"`
+	MaxCRs      int             `json:"maxCRs"`
+	MaxObjects  int             `json:"maxObjects"`
+	Memory      *CapacityFit    `json:"memory,omitempty"`
+	CPU         *CapacityFit    `json:"cpu,omitempty"`
+	Points      []CapacityPoint `json:"points"`
+}
+
+// GetOperandMode get how the operands are run from the OPERAND_MODE env variable. The helm chart probes the memcached
+// port so cannot use the pause image, and the go CRD does not allow a size of 0
+func GetOperandMode(oType string) (string, error) {
+	mode := os.Getenv("OPERAND_MODE")
+	switch mode {
+	case "":
+		return OperandMemcached, nil
+	case OperandMemcached:
+	case OperandPause:
+		if oType == HelmType {
+			return mode, fmt.Errorf("OPERAND_MODE %s is not supported by %s, use %s", mode, oType, OperandZero)
+		}
+	case OperandZero:
+		if oType == GoType {
+			return mode, fmt.Errorf("OPERAND_MODE %s is not supported by %s, use %s", mode, oType, OperandPause)
+		}
+	default:
+		return mode, fmt.Errorf("unknown OPERAND_MODE %q", mode)
+	}
+
+	return mode, nil
+}
+
+// KindMaxPodsFor get how many pods the kind node should run for the operand mode, 0 keeps the kubelet's default
+func KindMaxPodsFor(mode string) int {
+	if mode == OperandMemcached {
+		return 0
+	}
+
+	return KindMaxPods
+}
+
+// CheckOperandPods check the operand pods of count CRs fit on the kind node when they are scaled up to the largest of
+// sizes, such as by the update phase or the soak. With pause every CR runs at least one pod. With zero no operand
+// pods may run, so CRs cannot be scaled up and the coalescing scenario, which scales its CR with every patch, is
+// rejected
+func CheckOperandPods(mode string, count int, coalesce CoalesceConfig, sizes ...int64) error {
+	largest := int64(0)
+	for _, size := range sizes {
+		if size > largest {
+			largest = size
+		}
+	}
+
+	switch mode {
+	case OperandMemcached:
+		return nil
+	case OperandZero:
+		if largest > 0 {
+			return fmt.Errorf("OPERAND_MODE %s runs no operand pods, but CRs would be scaled up to %d", mode, largest)
+		}
+		if coalesce.Enabled() {
+			return fmt.Errorf("OPERAND_MODE %s runs no operand pods, but COALESCE_PATCHES scales a CR up", mode)
+		}
+		return nil
+	}
+
+	if largest < 1 {
+		largest = 1
+	}
+	if pods := int64(count) * largest; pods > KindMaxPods-KindReservedPods {
+		return fmt.Errorf("OPERAND_MODE %s runs up to %d operand pods for CR_COUNT %d CRs of size %d, more than "+
+			"the %d the kind node has room for", mode, pods, count, largest, KindMaxPods-KindReservedPods)
+	}
+
+	return nil
+}
+
+// GetPauseImage get the image the operands run with the pause operand mode from the PAUSE_IMAGE env variable
+func GetPauseImage() string {
+	if image := os.Getenv("PAUSE_IMAGE"); image != "" {
+		return image
+	}
+
+	return DefaultPauseImage
+}
+
+// UsePauseImage Patch the go controller or ansible role of a project in projectDir to run the operands with image
+// instead of memcached, must be called before the image is built
+func UsePauseImage(projectDir, oType, image string) error {
+	var file string
+	var command *regexp.Regexp
+	var pause func(match []byte) []byte
+	switch oType {
+	case GoType:
+		file, command = filepath.Join(projectDir, "controllers", "memcached_controller.go"), goMemcachedCommand
+		pause = func([]byte) []byte { return []byte(`[]string{"/pause"}`) }
+	case AnsibleType:
+		file, command = filepath.Join(projectDir, "roles", "memcached", "tasks", "main.yml"), ansibleMemcachedCommand
+		pause = pauseAnsibleCommand
+	default:
+		return fmt.Errorf("the operand image of %s cannot be replaced", oType)
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if !memcachedImage.Match(content) || !command.Match(content) {
+		return fmt.Errorf("memcached image or command not found in %s", file)
+	}
+	content = memcachedImage.ReplaceAll(content, []byte(strconv.Quote(image)))
+	content = command.ReplaceAllFunc(content, pause)
+
+	return os.WriteFile(file, content, 0644)
+}
+
+// pauseAnsibleCommand replace the memcached command list item and the flags following it at the same indent with
+// /pause. The regexp cannot match the indent of the flags to the command itself, so it is checked here
+func pauseAnsibleCommand(match []byte) []byte {
+	lines := strings.SplitAfter(string(match), "\n")
+	indent := lines[0][:strings.Index(lines[0], "-")]
+
+	end := 1
+	for end < len(lines) && strings.HasPrefix(lines[end], indent+"- ") {
+		end++
+	}
+
+	return []byte(indent + "- /pause\n" + strings.Join(lines[end:], ""))
+}
+
+// ObjectCounts count the CRs which exist and the objects owned by a CR directly or through other owned objects. Objects
+// of a deleted CR are counted until they are gone themselves, as the operator caches them until then
+func (l *LifecycleTracker) ObjectCounts() (crs, objects int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for uid := range l.crs {
+		if object, ok := l.objects[uid]; ok && object.goneAt.IsZero() {
+			crs++
+		}
+		for _, owned := range l.descendants(uid) {
+			if l.objects[owned].goneAt.IsZero() {
+				objects++
+			}
+		}
+	}
+
+	return crs, objects
+}
+
+// ObjectCounter Record how many objects the operator manages over the whole run, whenever the count changes
+type ObjectCounter struct {
+	tracker  *LifecycleTracker
+	timeline *Timeline
+
+	mu     sync.Mutex
+	counts []ObjectCount
+
+	loop *backgroundLoop
+}
+
+// NewObjectCounter create a counter of the objects seen by the tracker, labelled using the timeline phases
+func NewObjectCounter(tracker *LifecycleTracker, timeline *Timeline) *ObjectCounter {
+	return &ObjectCounter{tracker: tracker, timeline: timeline}
+}
+
+// Start counting the objects in the background until the context is cancelled or Stop is called
+func (o *ObjectCounter) Start(ctx context.Context) {
+	o.count()
+	o.loop = startLoop(ctx, tickerInterval, func(ctx context.Context) {
+		o.count()
+	})
+}
+
+// Stop counting the objects
+func (o *ObjectCounter) Stop() {
+	o.loop.stop()
+}
+
+// Counts get the object counts in the order they were recorded
+func (o *ObjectCounter) Counts() []ObjectCount {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]ObjectCount{}, o.counts...)
+}
+
+// count record the objects managed now if the count changed
+func (o *ObjectCounter) count() {
+	crs, objects := o.tracker.ObjectCounts()
+	phase, offset := o.timeline.Current()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if n := len(o.counts); n > 0 && o.counts[n-1].CRs == crs && o.counts[n-1].Objects == objects {
+		return
+	}
+	o.counts = append(o.counts, ObjectCount{Offset: offset.Milliseconds(), Phase: phase, CRs: crs, Objects: objects})
+}
+
+// CapacityPoints pair each usage point with the last object count recorded before it, points before the first count
+// are dropped
+func CapacityPoints(usage []UsagePoint, counts []ObjectCount) []CapacityPoint {
+	points := make([]CapacityPoint, 0, len(usage))
+	for _, point := range usage {
+		last := -1
+		for i, count := range counts {
+			if count.Offset > point.Offset {
+				break
+			}
+			last = i
+		}
+		if last < 0 {
+			continue
+		}
+		points = append(points, CapacityPoint{
+			Offset:        point.Offset,
+			Phase:         point.Phase,
+			CRs:           counts[last].CRs,
+			Objects:       counts[last].Objects,
+			CPUMillicores: point.CPUMillicores,
+			MemoryBytes:   point.MemoryBytes,
+		})
+	}
+
+	return points
+}
+
+// SummariseCapacity fit the usage of the manager against the number of CRs while the population grows
+func SummariseCapacity(operandMode string, points []CapacityPoint) CapacitySummary {
+	summary := CapacitySummary{OperandMode: operandMode, Points: points}
+
+	var crs, cpu, memory []float64
+	for _, point := range points {
+		if point.CRs > summary.MaxCRs {
+			summary.MaxCRs = point.CRs
+		}
+		if point.Objects > summary.MaxObjects {
+			summary.MaxObjects = point.Objects
+		}
+		switch point.Phase {
+		case PhaseBaseline, PhaseCreate, PhaseSteady:
+			crs = append(crs, float64(point.CRs))
+			cpu = append(cpu, float64(point.CPUMillicores))
+			memory = append(memory, float64(point.MemoryBytes))
+		}
+	}
+
+	// The fits are left out when the number of CRs never changed
+	if perCR, base, r2, err := fitLine(crs, memory); err == nil {
+		summary.Memory = &CapacityFit{PerCR: perCR, Base: base, R2: r2}
+	}
+	if perCR, base, r2, err := fitLine(crs, cpu); err == nil {
+		summary.CPU = &CapacityFit{PerCR: perCR, Base: base, R2: r2}
+	}
+
+	return summary
+}
@@ -0,0 +1,178 @@
+package testutils
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	fakeGoController = `				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Image:   "memcached:1.4.36-alpine",
+						Name:    "memcached",
+						Command: []string{"memcached", "-m=64", "-o", "modern", "-v"},
+					}},
+				},
+`
+	fakeAnsibleRole = `            containers:
+            - name: memcached
+              command:
+              - memcached
+              - -m=64
+              - -o
+              - modern
+              - -v
+              image: "docker.io/memcached:1.4.36-alpine"
+              ports:
+              - containerPort: 11211
+`
+)
+
+var _ = Describe("Capacity", func() {
+	AfterEach(func() {
+		Expect(os.Unsetenv("OPERAND_MODE")).To(Succeed())
+	})
+
+	It("should only allow the operand modes each type supports", func() {
+		mode, err := GetOperandMode(HelmType)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mode).To(Equal(OperandMemcached))
+
+		Expect(os.Setenv("OPERAND_MODE", OperandPause)).To(Succeed())
+		_, err = GetOperandMode(GoType)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = GetOperandMode(HelmType)
+		Expect(err).To(MatchError("OPERAND_MODE pause is not supported by helm, use zero"))
+
+		Expect(os.Setenv("OPERAND_MODE", OperandZero)).To(Succeed())
+		_, err = GetOperandMode(AnsibleType)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = GetOperandMode(GoType)
+		Expect(err).To(MatchError("OPERAND_MODE zero is not supported by go/v3, use pause"))
+	})
+
+	It("should check the operand pods fit on the kind node", func() {
+		Expect(KindMaxPodsFor(OperandMemcached)).To(Equal(0))
+		Expect(KindMaxPodsFor(OperandPause)).To(Equal(KindMaxPods))
+
+		coalesce := CoalesceConfig{Patches: 4, Bursts: 1}
+		Expect(CheckOperandPods(OperandMemcached, 10000, coalesce, 2)).To(Succeed())
+		Expect(CheckOperandPods(OperandZero, 10000, CoalesceConfig{}, 0)).To(Succeed())
+		Expect(CheckOperandPods(OperandPause, 3000, coalesce, 1, 0)).To(Succeed())
+		Expect(CheckOperandPods(OperandPause, 3000, CoalesceConfig{}, 1, 2)).To(MatchError(
+			"OPERAND_MODE pause runs up to 6000 operand pods for CR_COUNT 3000 CRs of size 2, more than the 3900 " +
+				"the kind node has room for"))
+		Expect(CheckOperandPods(OperandZero, 10, CoalesceConfig{}, 0, 2)).To(MatchError(
+			"OPERAND_MODE zero runs no operand pods, but CRs would be scaled up to 2"))
+		Expect(CheckOperandPods(OperandZero, 10, coalesce, 0)).To(MatchError(
+			"OPERAND_MODE zero runs no operand pods, but COALESCE_PATCHES scales a CR up"))
+	})
+
+	It("should swap the memcached operand for the pause image", func() {
+		dir, err := os.MkdirTemp("", "project")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		controller := filepath.Join(dir, "controllers", "memcached_controller.go")
+		role := filepath.Join(dir, "roles", "memcached", "tasks", "main.yml")
+		for file, content := range map[string]string{controller: fakeGoController, role: fakeAnsibleRole} {
+			Expect(os.MkdirAll(filepath.Dir(file), 0755)).To(Succeed())
+			Expect(os.WriteFile(file, []byte(content), 0644)).To(Succeed())
+		}
+
+		Expect(UsePauseImage(dir, GoType, DefaultPauseImage)).To(Succeed())
+		patched, err := os.ReadFile(controller)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(patched)).To(ContainSubstring(`Image:   "registry.k8s.io/pause:3.7",`))
+		Expect(string(patched)).To(ContainSubstring(`Command: []string{"/pause"},`))
+		Expect(string(patched)).NotTo(ContainSubstring("-m=64"))
+		Expect(string(patched)).NotTo(ContainSubstring(`"-v"`))
+
+		Expect(UsePauseImage(dir, AnsibleType, DefaultPauseImage)).To(Succeed())
+		patched, err = os.ReadFile(role)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(patched)).To(ContainSubstring("              command:\n              - /pause\n              image: "))
+		Expect(string(patched)).NotTo(ContainSubstring("-m=64"))
+		Expect(string(patched)).NotTo(ContainSubstring("- -v"))
+		Expect(string(patched)).NotTo(ContainSubstring("- modern"))
+		Expect(string(patched)).To(ContainSubstring("              ports:\n              - containerPort: 11211\n"))
+		Expect(string(patched)).To(ContainSubstring(`image: "registry.k8s.io/pause:3.7"`))
+		Expect(string(patched)).To(ContainSubstring("- name: memcached\n"))
+
+		Expect(UsePauseImage(dir, AnsibleType, DefaultPauseImage)).To(MatchError(ContainSubstring("memcached image or command not found")))
+	})
+
+	It("should count the CRs and the objects they own", func() {
+		timeline := NewTimeline()
+		tracker := NewLifecycleTracker(fake.NewSimpleClientset(), newFakeDynamicClient(), memcachedGVR, StatusNodesReady,
+			timeline, Namespace)
+		counter := NewObjectCounter(tracker, timeline)
+
+		cr := fakeMemcached("memcached-sample00", "cr")
+		tracker.observe(cr)
+		tracker.observe(fakeMemcached("memcached-sample01", "other-cr"))
+		tracker.observe(&appsv1.Deployment{ObjectMeta: ownedBy("memcached-sample00", "deployment", "cr")})
+		tracker.observe(&appsv1.ReplicaSet{ObjectMeta: ownedBy("memcached-sample00-abc", "replicaset", "deployment")})
+		tracker.observe(&corev1.Pod{ObjectMeta: ownedBy("memcached-sample00-abc-xyz", "pod", "replicaset")})
+		// Objects the operator does not manage are left out
+		tracker.observe(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "kube-system", UID: "coredns"}})
+		counter.count()
+		counter.count()
+
+		timeline.Mark(PhaseDelete)
+		tracker.gone(cr)
+		counter.count()
+		tracker.gone(&corev1.Pod{ObjectMeta: ownedBy("memcached-sample00-abc-xyz", "pod", "replicaset")})
+		counter.count()
+
+		counts := counter.Counts()
+		Expect(counts).To(HaveLen(3))
+		Expect(counts[0].Phase).To(Equal(PhaseBaseline))
+		Expect(counts[0].CRs).To(Equal(2))
+		Expect(counts[0].Objects).To(Equal(3))
+		// The objects of a deleted CR are counted until they are seen gone themselves
+		Expect(counts[1].Phase).To(Equal(PhaseDelete))
+		Expect(counts[1].CRs).To(Equal(1))
+		Expect(counts[1].Objects).To(Equal(3))
+		Expect(counts[2].Objects).To(Equal(2))
+	})
+
+	It("should fit the usage of the manager against the number of CRs while they are created", func() {
+		counts := []ObjectCount{
+			{Offset: 1000, CRs: 0},
+			{Offset: 3000, CRs: 100, Objects: 300},
+			{Offset: 5000, CRs: 200, Objects: 600},
+		}
+		usage := []UsagePoint{
+			{Offset: 500, Phase: PhaseBaseline, MemoryBytes: 1},
+			{Offset: 2000, Phase: PhaseBaseline, CPUMillicores: 10, MemoryBytes: 20_000_000},
+			{Offset: 4000, Phase: PhaseCreate, CPUMillicores: 60, MemoryBytes: 30_000_000},
+			{Offset: 6000, Phase: PhaseSteady, CPUMillicores: 110, MemoryBytes: 40_000_000},
+			// Memory is not returned once the CRs are deleted so the delete phase is left out of the fits
+			{Offset: 7000, Phase: PhaseDelete, CPUMillicores: 200, MemoryBytes: 40_000_000},
+		}
+
+		points := CapacityPoints(usage, counts)
+		Expect(points).To(HaveLen(4))
+		Expect(points[1]).To(Equal(CapacityPoint{Offset: 4000, Phase: PhaseCreate, CRs: 100, Objects: 300,
+			CPUMillicores: 60, MemoryBytes: 30_000_000}))
+
+		summary := SummariseCapacity(OperandPause, points)
+		Expect(summary.OperandMode).To(Equal(OperandPause))
+		Expect(summary.MaxCRs).To(Equal(200))
+		Expect(summary.MaxObjects).To(Equal(600))
+		Expect(summary.Memory.PerCR).To(BeNumerically("~", 100_000, 1e-6))
+		Expect(summary.Memory.Base).To(BeNumerically("~", 20_000_000, 1e-6))
+		Expect(summary.Memory.R2).To(BeNumerically("~", 1, 1e-9))
+		Expect(summary.CPU.PerCR).To(BeNumerically("~", 0.5, 1e-9))
+
+		Expect(SummariseCapacity(OperandPause, points[:1]).Memory).To(BeNil())
+	})
+})
@@ -0,0 +1,182 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"time"
+)
+
+const (
+	// CoalesceCRName is the CR the coalescing scenario patches, kept apart from the CRs of the main workload
+	CoalesceCRName = "memcached-coalesce"
+	// CoalesceQuietPeriod is how long the reconcile count has to stay the same for the operator to be done with a burst
+	CoalesceQuietPeriod   = 5 * time.Second
+	DefaultCoalesceBursts = 3
+	coalesceLabel         = "coalesce"
+)
+
+// ReconcileCounter get the number of reconciles the operator has run so far
+type ReconcileCounter func(ctx context.Context) (float64, error)
+
+// CoalesceConfig How many bursts of how many patches to send to the same CR, no bursts are sent if Patches is 0
+type CoalesceConfig struct {
+	Patches int `json:"patches"`
+	Bursts  int `json:"bursts"`
+}
+
+// CoalesceBurst How many reconciles a burst of patches to the same CR caused and how long the operator took to
+// converge on the spec of the last patch
+type CoalesceBurst struct {
+	Burst   int `json:"burst"`
+	Patches int `json:"patches"`
+	// FinalSize is the size set by the last patch
+	FinalSize int64 `json:"finalSize"`
+	// Reconciles is the increase of controller_runtime_reconcile_total from before the burst until the operator was done
+	Reconciles float64 `json:"reconciles"`
+	// SentMs is how long sending every patch took, ConvergedMs is from the first patch being sent until the pods of the
+	// CR matched the last one
+	SentMs      int64 `json:"sentMs"`
+	ConvergedMs int64 `json:"convergedMs"`
+}
+
+// CoalesceSummary The bursts sent and the mean share of patches which caused a reconcile, 1 means none were coalesced
+type CoalesceSummary struct {
+	RunID              string          `json:"runId"`
+	Config             CoalesceConfig  `json:"config"`
+	Bursts             []CoalesceBurst `json:"bursts"`
+	ReconcilesPerPatch float64         `json:"reconcilesPerPatch"`
+	MeanConvergedMs    float64         `json:"meanConvergedMs"`
+}
+
+// GetCoalesceConfig get the coalescing scenario from the COALESCE_PATCHES and COALESCE_BURSTS env variables
+func GetCoalesceConfig() (CoalesceConfig, error) {
+	config := CoalesceConfig{}
+
+	var err error
+	if config.Patches, err = intFromEnv("COALESCE_PATCHES", 0); err != nil {
+		return config, err
+	}
+	if config.Bursts, err = intFromEnv("COALESCE_BURSTS", DefaultCoalesceBursts); err != nil {
+		return config, err
+	}
+	if config.Patches < 0 || config.Bursts < 1 {
+		return config, fmt.Errorf("COALESCE_PATCHES must not be negative and COALESCE_BURSTS must be positive")
+	}
+
+	return config, nil
+}
+
+// Enabled check whether the coalescing scenario should be run
+func (c CoalesceConfig) Enabled() bool {
+	return c.Patches > 0
+}
+
+// CoalesceSizes get the sizes set by each patch of a burst to a CR of size current. The patches alternate between the
+// two smallest sizes other than current, so every patch changes the spec and the last one always scales the CR
+func CoalesceSizes(patches int, current int64) []int64 {
+	alternate := make([]int64, 0, 2)
+	for size := int64(1); len(alternate) < 2; size++ {
+		if size != current {
+			alternate = append(alternate, size)
+		}
+	}
+
+	sizes := make([]int64, patches)
+	for i := range sizes {
+		sizes[i] = alternate[i%2]
+	}
+
+	return sizes
+}
+
+// RunCoalesceBurst send the patches of a burst to the CR, currently of size current, as fast as they are answered,
+// then wait until the pods of the CR match the last patch and the reconcile count has stopped changing, or timeout
+// passes
+func RunCoalesceBurst(ctx context.Context, workload *WorkloadDriver, tracker *LifecycleTracker, countReconciles ReconcileCounter,
+	cr *unstructured.Unstructured, oType string, burst, patches int, current int64, timeout time.Duration) (CoalesceBurst, error) {
+	sizes := CoalesceSizes(patches, current)
+	result := CoalesceBurst{Burst: burst, Patches: patches, FinalSize: sizes[len(sizes)-1]}
+	before, err := countReconciles(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	start := time.Now()
+	for _, size := range sizes {
+		patched, err := WithSize(cr, oType, size)
+		if err != nil {
+			return result, err
+		}
+		if err := workload.Apply(ctx, patched); err != nil {
+			return result, err
+		}
+	}
+	sent := time.Now()
+	result.SentMs = sent.Sub(start).Milliseconds()
+	// Requested once every patch was sent, an earlier patch setting the same size could otherwise count as converged
+	key := types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}
+	tracker.ScaleRequested(key, coalesceLabel, int32(result.FinalSize), sent)
+
+	deadline := start.Add(timeout)
+	last, lastChanged := before, time.Now()
+	for {
+		if !sleepContext(ctx, time.Second) {
+			return result, ctx.Err()
+		}
+		count, err := countReconciles(ctx)
+		if err != nil {
+			return result, err
+		}
+		if count != last {
+			last, lastChanged = count, time.Now()
+		}
+
+		if scale, ok := lastScale(tracker, key); ok && scale.Reached != nil &&
+			time.Since(lastChanged) >= CoalesceQuietPeriod {
+			result.Reconciles = last - before
+			result.ConvergedMs = *scale.Reached - start.Sub(tracker.timeline.Start()).Milliseconds()
+			return result, nil
+		}
+		if time.Now().After(deadline) {
+			return result, fmt.Errorf("burst %d did not converge on size %d within %v", burst, result.FinalSize, timeout)
+		}
+	}
+}
+
+// lastScale get the last scale of the CR requested by a coalescing burst
+func lastScale(tracker *LifecycleTracker, key types.NamespacedName) (CRScale, bool) {
+	var (
+		last  CRScale
+		found bool
+	)
+	for _, scale := range tracker.Scales() {
+		if scale.Namespace == key.Namespace && scale.Name == key.Name && scale.Label == coalesceLabel {
+			last, found = scale, true
+		}
+	}
+
+	return last, found
+}
+
+// SummariseCoalescing get the share of patches which caused a reconcile and the mean time to converge over the bursts
+func SummariseCoalescing(config CoalesceConfig, bursts []CoalesceBurst) CoalesceSummary {
+	summary := CoalesceSummary{Config: config, Bursts: bursts}
+	if len(bursts) == 0 {
+		return summary
+	}
+
+	patches, reconciles, converged := 0, 0.0, 0.0
+	for _, burst := range bursts {
+		patches += burst.Patches
+		reconciles += burst.Reconciles
+		converged += float64(burst.ConvergedMs)
+	}
+	if patches > 0 {
+		summary.ReconcilesPerPatch = reconciles / float64(patches)
+	}
+	summary.MeanConvergedMs = converged / float64(len(bursts))
+
+	return summary
+}
@@ -0,0 +1,133 @@
+package testutils
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Coalescing", func() {
+	It("should be disabled unless COALESCE_PATCHES is set", func() {
+		config, err := GetCoalesceConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.Enabled()).To(BeFalse())
+
+		Expect(os.Setenv("COALESCE_PATCHES", "20")).To(Succeed())
+		defer os.Unsetenv("COALESCE_PATCHES")
+		config, err = GetCoalesceConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config).To(Equal(CoalesceConfig{Patches: 20, Bursts: DefaultCoalesceBursts}))
+		Expect(config.Enabled()).To(BeTrue())
+	})
+
+	It("should sum the reconciles of every controller and result", func() {
+		parser := expfmt.TextParser{}
+		families, err := parser.TextToMetricFamilies(strings.NewReader(`# TYPE controller_runtime_reconcile_total counter
+controller_runtime_reconcile_total{controller="memcached",result="success"} 12
+controller_runtime_reconcile_total{controller="memcached",result="error"} 2
+controller_runtime_reconcile_total{controller="memcached",result="requeue"} 1
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ReconcileTotal(families)).To(Equal(15.0))
+
+		_, err = ReconcileTotal(map[string]*dto.MetricFamily{})
+		Expect(err).To(MatchError("metric controller_runtime_reconcile_total not found"))
+	})
+
+	It("should change the size with every patch, starting from the current size", func() {
+		Expect(CoalesceSizes(3, 1)).To(Equal([]int64{2, 3, 2}))
+		Expect(CoalesceSizes(1, 0)).To(Equal([]int64{1}))
+	})
+
+	It("should scale the CR with every burst of an even number of patches", func() {
+		current := int64(1)
+		for burst := 0; burst < 3; burst++ {
+			sizes := CoalesceSizes(4, current)
+			Expect(sizes).To(HaveLen(4))
+			Expect(sizes[0]).NotTo(Equal(current))
+			for i := 1; i < len(sizes); i++ {
+				Expect(sizes[i]).NotTo(Equal(sizes[i-1]))
+			}
+			final := sizes[len(sizes)-1]
+			Expect(final).NotTo(Equal(current))
+			current = final
+		}
+	})
+
+	It("should count the reconciles of a burst and when the CR converged", func() {
+		ctx := context.TODO()
+		clientset := fake.NewSimpleClientset()
+		dynamicClient := newFakeDynamicClient()
+		reactToApply(dynamicClient)
+		timeline := NewTimeline()
+		tracker := NewLifecycleTracker(clientset, dynamicClient, memcachedGVR, StatusNodesReady, timeline, Namespace)
+		Expect(tracker.Start(ctx)).To(Succeed())
+		defer tracker.Stop()
+		workload := NewWorkloadDriver(dynamicClient, memcachedGVR, timeline, tracker)
+
+		cr := fakeMemcached(CoalesceCRName, "")
+		Expect(workload.Apply(ctx, cr)).To(Succeed())
+		cr, err := dynamicClient.Resource(memcachedGVR).Namespace(Namespace).Get(ctx, CoalesceCRName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		replicas := int32(1)
+		deployment := &appsv1.Deployment{
+			ObjectMeta: ownedBy(CoalesceCRName, "deployment", cr.GetUID()),
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsv1.DeploymentStatus{ReadyReplicas: 1},
+		}
+		_, err = clientset.AppsV1().Deployments(Namespace).Create(ctx, deployment, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		// The operator reconciles twice, then the count stays the same
+		var (
+			mu     sync.Mutex
+			counts = []float64{10, 11, 12}
+		)
+		countReconciles := func(context.Context) (float64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			count := counts[0]
+			if len(counts) > 1 {
+				counts = counts[1:]
+			}
+			return count, nil
+		}
+
+		go func() {
+			defer GinkgoRecover()
+			Eventually(workload.Requests).Should(HaveLen(4))
+			time.Sleep(5 * time.Millisecond)
+			replicas = 2
+			deployment.Status.ReadyReplicas = 2
+			_, err := clientset.AppsV1().Deployments(Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		}()
+
+		burst, err := RunCoalesceBurst(ctx, workload, tracker, countReconciles, cr, GoType, 0, 3, 1, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(burst.FinalSize).To(Equal(int64(2)))
+		Expect(burst.Reconciles).To(Equal(2.0))
+		Expect(burst.ConvergedMs).To(BeNumerically(">=", burst.SentMs))
+
+		cr, err = dynamicClient.Resource(memcachedGVR).Namespace(Namespace).Get(ctx, CoalesceCRName, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		size, _, err := unstructured.NestedInt64(cr.Object, "spec", "size")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(int64(2)))
+
+		summary := SummariseCoalescing(CoalesceConfig{Patches: 3, Bursts: 1}, []CoalesceBurst{burst})
+		Expect(summary.ReconcilesPerPatch).To(BeNumerically("~", 2.0/3, 0.001))
+		Expect(summary.MeanConvergedMs).To(Equal(float64(burst.ConvergedMs)))
+	})
+})
@@ -1,13 +1,10 @@
 package testutils
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
-	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
 	"os"
 	"reflect"
 	"sort"
@@ -24,46 +21,6 @@ const (
 	OperatorPodLabel  = "control-plane=controller-manager"
 )
 
-// GatherMetricsForDuration Gather operator pod metrics for a specific duration
-func GatherMetricsForDuration(metricsClient *metricsv.Clientset, tickerDuration time.Duration) []v1beta1.PodMetrics {
-	var metrics []v1beta1.PodMetrics
-
-	ticker := time.NewTicker(tickerInterval)
-	defer ticker.Stop()
-	done := make(chan bool)
-
-	go func() {
-		time.Sleep(tickerDuration)
-		done <- true
-	}()
-
-	for {
-		select {
-		case <-done:
-			return metrics
-		case <-ticker.C:
-			podMetricsList, err := metricsClient.MetricsV1beta1().PodMetricses(Namespace).List(context.TODO(), metav1.ListOptions{
-				LabelSelector: OperatorPodLabel,
-			})
-			if err != nil {
-				continue
-			}
-
-			for _, podMetric := range podMetricsList.Items {
-				sortContainersByName(podMetric.Containers)
-			}
-
-			metrics = append(metrics, podMetricsList.Items...)
-		}
-	}
-}
-
-// GatherMetricsToChannel Gather Pod metrics and send to channel
-func GatherMetricsToChannel(metricsClient *metricsv.Clientset, tickerDuration time.Duration, metricsChannel chan []v1beta1.PodMetrics) {
-	metricsChannel <- GatherMetricsForDuration(metricsClient, tickerDuration)
-	println("Sent gathered metrics to channel")
-}
-
 // sortContainersByName Sort container metrics by name
 func sortContainersByName(elems []v1beta1.ContainerMetrics) {
 	sort.Slice(elems, func(i, j int) bool {
@@ -71,9 +28,8 @@ func sortContainersByName(elems []v1beta1.ContainerMetrics) {
 	})
 }
 
-// SaveAsJsonToDir Marshal object to json and save to directory
-func SaveAsJsonToDir(dir string, object interface{}) error {
-	// Create result directory
+// MakeResultsDir Create directory in the results directory and return its path
+func MakeResultsDir(dir string) (string, error) {
 	resultsDir := os.Getenv("RESULTS_DIR")
 	if resultsDir == "" {
 		resultsDir = DefaultResultsDir
@@ -82,10 +38,21 @@ func SaveAsJsonToDir(dir string, object interface{}) error {
 	path := fmt.Sprintf("%s/%s", resultsDir, dir)
 	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
 		if err := os.MkdirAll(path, os.ModePerm); err != nil {
-			return err
+			return "", err
 		}
 	}
 
+	return path, nil
+}
+
+// SaveAsJsonToDir Marshal object to json and save to directory
+func SaveAsJsonToDir(dir string, object interface{}) error {
+	// Create result directory
+	path, err := MakeResultsDir(dir)
+	if err != nil {
+		return err
+	}
+
 	// Marshal to json
 	if reflect.TypeOf(object).String() != "string" {
 		bytes, err := json.MarshalIndent(object, "", "  ")
@@ -99,6 +66,29 @@ func SaveAsJsonToDir(dir string, object interface{}) error {
 	return SaveJSONStringToDir(path, object.(string))
 }
 
+// WithRunID Add the run ID to the metadata of a list of objects saved from kubectl, so it can be linked to the metadata
+// of the run
+func WithRunID(list string, runID string) (string, error) {
+	object := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(list), &object); err != nil {
+		return "", err
+	}
+
+	metadata, _ := object["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["runId"] = runID
+	object["metadata"] = metadata
+
+	bytes, err := json.MarshalIndent(object, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes), nil
+}
+
 // SaveJSONStringToDir Save JSON string to directory
 func SaveJSONStringToDir(path string, jsonString string) error {
 	// Create json file
@@ -0,0 +1,24 @@
+package testutils
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WithRunID", func() {
+	It("should add the run ID to the metadata of a list", func() {
+		list, err := WithRunID(`{"apiVersion":"v1","kind":"List","items":[{"metadata":{"name":"memcached-sample00"}}],
+"metadata":{"resourceVersion":""}}`, "1659348000000000")
+		Expect(err).NotTo(HaveOccurred())
+
+		object := map[string]interface{}{}
+		Expect(json.Unmarshal([]byte(list), &object)).To(Succeed())
+		Expect(object["metadata"]).To(Equal(map[string]interface{}{"resourceVersion": "", "runId": "1659348000000000"}))
+		Expect(object["items"]).To(HaveLen(1))
+
+		_, err = WithRunID("not json", "1659348000000000")
+		Expect(err).To(HaveOccurred())
+	})
+})
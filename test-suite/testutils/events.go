@@ -0,0 +1,245 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"os"
+	"sync"
+	"time"
+)
+
+// EventObject The object an event is about
+type EventObject struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// EventRecord An occurrence of a Kubernetes event tagged with the phase of the run it was seen in
+type EventRecord struct {
+	Namespace      string      `json:"namespace"`
+	Name           string      `json:"name"`
+	InvolvedObject EventObject `json:"involvedObject"`
+	// Type is Normal or Warning
+	Type    string `json:"type"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Source  string `json:"source,omitempty"`
+	// Count is the number of times the event has happened, repeats update the same event rather than creating a new one
+	Count int32 `json:"count"`
+	// Time is when the event last happened
+	Time  time.Time `json:"time"`
+	Phase Phase     `json:"phase"`
+	// Offset is the number of milliseconds since the start of the run
+	Offset     int64     `json:"offset"`
+	ObservedAt time.Time `json:"observedAt"`
+}
+
+// GetEventsNamespace get the namespace to watch events in, all namespaces if CLUSTER_EVENTS is true
+func GetEventsNamespace() string {
+	if os.Getenv("CLUSTER_EVENTS") == "true" {
+		return ""
+	}
+
+	return Namespace
+}
+
+// eventOccurrence identifies an occurrence of an event, repeats of the event increase its count
+type eventOccurrence struct {
+	uid   types.UID
+	count int32
+}
+
+// EventWatcher Watch the events in a namespace, or all namespaces, streaming every occurrence seen during the run
+// in the order they were seen
+type EventWatcher struct {
+	clientset kubernetes.Interface
+	timeline  *Timeline
+	namespace string
+
+	mu       sync.Mutex
+	writer   *StreamWriter
+	writeErr error
+	records  int
+	seen     map[eventOccurrence]struct{}
+	stopCh   chan struct{}
+}
+
+// NewEventWatcher create a watcher for the events in namespace, or all namespaces if it is empty
+func NewEventWatcher(clientset kubernetes.Interface, timeline *Timeline, namespace string) *EventWatcher {
+	return &EventWatcher{
+		clientset: clientset,
+		timeline:  timeline,
+		namespace: namespace,
+		seen:      map[eventOccurrence]struct{}{},
+	}
+}
+
+// Start watching the events, streaming them to events in the results directory dir.
+// Events which last happened before the run started are skipped
+func (e *EventWatcher) Start(ctx context.Context, dir string) error {
+	writer, err := NewStreamWriter(fmt.Sprintf("%s/events", dir))
+	if err != nil {
+		return err
+	}
+	e.writer = writer
+
+	factory := informers.NewSharedInformerFactoryWithOptions(e.clientset, 0, informers.WithNamespace(e.namespace))
+	informer := factory.Core().V1().Events().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if event, ok := obj.(*corev1.Event); ok {
+				e.record(event)
+			}
+		},
+		UpdateFunc: func(oldObj, obj interface{}) {
+			old, ok := oldObj.(*corev1.Event)
+			event, newOk := obj.(*corev1.Event)
+			// Resyncs and relists replay events which did not change
+			if !ok || !newOk || (event.ResourceVersion != "" && old.ResourceVersion == event.ResourceVersion) {
+				return
+			}
+			e.record(event)
+		},
+	})
+
+	stopCh := make(chan struct{})
+	e.mu.Lock()
+	e.stopCh = stopCh
+	e.mu.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+			e.stop()
+		case <-stopCh:
+		}
+	}()
+	factory.Start(stopCh)
+	for informerType, synced := range factory.WaitForCacheSync(stopCh) {
+		if !synced {
+			return fmt.Errorf("failed to sync informer for %v", informerType)
+		}
+	}
+
+	return nil
+}
+
+// Stop watching the events and mark the stream complete
+func (e *EventWatcher) Stop() error {
+	return e.finish(true)
+}
+
+// Close stop watching the events if Stop was not called and mark the stream incomplete
+func (e *EventWatcher) Close() error {
+	return e.finish(false)
+}
+
+// Records get the number of event occurrences streamed
+func (e *EventWatcher) Records() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.records
+}
+
+// record stream an occurrence of the event if it happened during the run and was not streamed before
+func (e *EventWatcher) record(event *corev1.Event) {
+	// Event times are usually only precise to the second
+	at := eventTime(event)
+	if at.Before(e.timeline.Start().Truncate(time.Second)) {
+		return
+	}
+
+	phase, offset := e.timeline.Current()
+	record := EventRecord{
+		Namespace: event.Namespace,
+		Name:      event.Name,
+		InvolvedObject: EventObject{
+			Kind:      event.InvolvedObject.Kind,
+			Namespace: event.InvolvedObject.Namespace,
+			Name:      event.InvolvedObject.Name,
+		},
+		Type:       event.Type,
+		Reason:     event.Reason,
+		Message:    event.Message,
+		Source:     event.Source.Component,
+		Count:      event.Count,
+		Time:       at,
+		Phase:      phase,
+		Offset:     offset.Milliseconds(),
+		ObservedAt: time.Now(),
+	}
+	if record.Source == "" {
+		record.Source = event.ReportingController
+	}
+	if event.Series != nil {
+		record.Count = event.Series.Count
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.writer == nil {
+		return
+	}
+	occurrence := eventOccurrence{uid: event.UID, count: record.Count}
+	if _, ok := e.seen[occurrence]; ok {
+		return
+	}
+	e.seen[occurrence] = struct{}{}
+	if err := e.writer.Write(record); err != nil {
+		if e.writeErr == nil {
+			e.writeErr = err
+		}
+		return
+	}
+	e.records++
+}
+
+// eventTime get when an event last happened, events.k8s.io clients set different fields than core/v1 clients
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// stop the informers if they are running
+func (e *EventWatcher) stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.stopCh != nil {
+		close(e.stopCh)
+		e.stopCh = nil
+	}
+}
+
+// finish stop watching the events and write the index of the stream
+func (e *EventWatcher) finish(complete bool) error {
+	e.stop()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.writer == nil {
+		return nil
+	}
+	err := e.writeErr
+	if finishErr := e.writer.Finish(complete, e.timeline); err == nil {
+		err = finishErr
+	}
+
+	return err
+}
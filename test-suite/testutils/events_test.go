@@ -0,0 +1,88 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeEvent create an event about a pod which last happened at lastTimestamp
+func fakeEvent(name, reason string, count int32, lastTimestamp time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: Namespace, UID: types.UID(name), ResourceVersion: "1"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: Namespace, Name: "memcached-sample00-0"},
+		Type:           corev1.EventTypeWarning,
+		Reason:         reason,
+		Message:        "Back-off pulling image",
+		Source:         corev1.EventSource{Component: "kubelet"},
+		Count:          count,
+		LastTimestamp:  metav1.NewTime(lastTimestamp),
+	}
+}
+
+var _ = Describe("EventWatcher", func() {
+	var resultsDir string
+
+	BeforeEach(func() {
+		var err error
+		resultsDir, err = os.MkdirTemp("", "results")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Setenv("RESULTS_DIR", resultsDir)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.Unsetenv("RESULTS_DIR")).To(Succeed())
+		Expect(os.RemoveAll(resultsDir)).To(Succeed())
+	})
+
+	It("should stream the events which happened during the run", func() {
+		timeline := NewTimeline()
+		clientset := fake.NewSimpleClientset(fakeEvent("before-run", "Pulled", 1, timeline.Start().Add(-time.Hour)))
+		watcher := NewEventWatcher(clientset, timeline, Namespace)
+		Expect(watcher.Start(context.TODO(), "events-test")).To(Succeed())
+
+		timeline.Mark(PhaseCreate)
+		event := fakeEvent("backoff", "BackOff", 1, time.Now())
+		_, err := clientset.CoreV1().Events(Namespace).Create(context.TODO(), event, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(watcher.Records).Should(Equal(1))
+
+		// An update which did not change the event is not streamed again
+		_, err = clientset.CoreV1().Events(Namespace).Update(context.TODO(), event, metav1.UpdateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		event.Count = 2
+		event.ResourceVersion = "2"
+		_, err = clientset.CoreV1().Events(Namespace).Update(context.TODO(), event, metav1.UpdateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(watcher.Records).Should(Equal(2))
+		// A relist replaying an occurrence already streamed is skipped
+		watcher.record(event)
+		Consistently(watcher.Records, 100*time.Millisecond).Should(Equal(2))
+		Expect(watcher.Stop()).To(Succeed())
+
+		files, err := filepath.Glob(filepath.Join(resultsDir, "events-test", "events", "*.ndjson"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		records, index, err := LoadStream(files[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(index.Complete).To(BeTrue())
+		Expect(records).To(HaveLen(2))
+
+		record := EventRecord{}
+		Expect(json.Unmarshal(records[1], &record)).To(Succeed())
+		Expect(record.Reason).To(Equal("BackOff"))
+		Expect(record.InvolvedObject).To(Equal(EventObject{Kind: "Pod", Namespace: Namespace, Name: "memcached-sample00-0"}))
+		Expect(record.Source).To(Equal("kubelet"))
+		Expect(record.Count).To(Equal(int32(2)))
+		Expect(record.Phase).To(Equal(PhaseCreate))
+	})
+})
@@ -0,0 +1,123 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// HelmReleaseLabel selects the Secrets helm stores the history of its releases in
+	HelmReleaseLabel      = "owner=helm"
+	HelmReleaseSecretType = "helm.sh/release.v1"
+)
+
+// HelmRelease The Secrets storing the revisions of a release, one per CR for the helm operator
+type HelmRelease struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Secrets   int    `json:"secrets"`
+	Bytes     int    `json:"bytes"`
+	Revisions []int  `json:"revisions"`
+	// Status is the status of the latest revision, e.g. deployed
+	Status string `json:"status"`
+}
+
+// HelmReleaseSnapshot The release Secrets in a namespace, or every namespace, at a point of the run
+type HelmReleaseSnapshot struct {
+	Phase Phase `json:"phase"`
+	// Offset is the number of milliseconds since the start of the run
+	Offset     int64     `json:"offset"`
+	ObservedAt time.Time `json:"observedAt"`
+	Secrets    int       `json:"secrets"`
+	// Bytes is the size of the data of the Secrets, which is most of what they cost to store in etcd
+	Bytes    int           `json:"bytes"`
+	Releases []HelmRelease `json:"releases"`
+}
+
+// SnapshotHelmReleases list the helm release Secrets in the namespace, grouped by release. Releases of the same name
+// in different namespaces are kept apart when namespace is empty
+func SnapshotHelmReleases(ctx context.Context, clientset kubernetes.Interface, timeline *Timeline, namespace string) (*HelmReleaseSnapshot, error) {
+	secrets, err := clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{LabelSelector: HelmReleaseLabel})
+	if err != nil {
+		return nil, err
+	}
+
+	phase, offset := timeline.Current()
+	snapshot := &HelmReleaseSnapshot{
+		Phase:      phase,
+		Offset:     offset.Milliseconds(),
+		ObservedAt: time.Now(),
+		Releases:   []HelmRelease{},
+	}
+
+	releases := map[types.NamespacedName]*HelmRelease{}
+	latest := map[types.NamespacedName]int{}
+	for _, secret := range secrets.Items {
+		if secret.Type != HelmReleaseSecretType {
+			continue
+		}
+
+		key := types.NamespacedName{Namespace: secret.Namespace, Name: secret.Labels["name"]}
+		if _, ok := releases[key]; !ok {
+			releases[key] = &HelmRelease{Namespace: key.Namespace, Name: key.Name, Revisions: []int{}}
+		}
+		release := releases[key]
+
+		size := 0
+		for _, value := range secret.Data {
+			size += len(value)
+		}
+		release.Secrets++
+		release.Bytes += size
+		snapshot.Secrets++
+		snapshot.Bytes += size
+
+		revision, err := strconv.Atoi(secret.Labels["version"])
+		if err != nil {
+			return nil, fmt.Errorf("release secret %s/%s has no revision: %v", secret.Namespace, secret.Name, err)
+		}
+		release.Revisions = append(release.Revisions, revision)
+		if revision >= latest[key] {
+			latest[key] = revision
+			release.Status = secret.Labels["status"]
+		}
+	}
+
+	for _, release := range releases {
+		sort.Ints(release.Revisions)
+		snapshot.Releases = append(snapshot.Releases, *release)
+	}
+	sort.Slice(snapshot.Releases, func(i, j int) bool {
+		if snapshot.Releases[i].Namespace != snapshot.Releases[j].Namespace {
+			return snapshot.Releases[i].Namespace < snapshot.Releases[j].Namespace
+		}
+		return snapshot.Releases[i].Name < snapshot.Releases[j].Name
+	})
+
+	return snapshot, nil
+}
+
+// Leftover get the releases in the snapshot which are in keys, such as releases of deleted CRs which were never
+// garbage collected
+func (s *HelmReleaseSnapshot) Leftover(keys []types.NamespacedName) []types.NamespacedName {
+	wanted := map[types.NamespacedName]struct{}{}
+	for _, key := range keys {
+		wanted[key] = struct{}{}
+	}
+
+	leftover := []types.NamespacedName{}
+	for _, release := range s.Releases {
+		key := types.NamespacedName{Namespace: release.Namespace, Name: release.Name}
+		if _, ok := wanted[key]; ok {
+			leftover = append(leftover, key)
+		}
+	}
+
+	return leftover
+}
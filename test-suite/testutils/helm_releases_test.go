@@ -0,0 +1,82 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeReleaseSecret create the Secret helm stores a revision of a release in
+func fakeReleaseSecret(release string, revision int, status string, size int) *corev1.Secret {
+	return fakeReleaseSecretIn(Namespace, release, revision, status, size)
+}
+
+// fakeReleaseSecretIn create the Secret helm stores a revision of a release in, in namespace
+func fakeReleaseSecretIn(namespace, release string, revision int, status string, size int) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("sh.helm.release.v1.%s.v%d", release, revision),
+			Namespace: namespace,
+			Labels: map[string]string{
+				"owner":   "helm",
+				"name":    release,
+				"version": fmt.Sprint(revision),
+				"status":  status,
+			},
+		},
+		Type: HelmReleaseSecretType,
+		Data: map[string][]byte{"release": make([]byte, size)},
+	}
+}
+
+var _ = Describe("SnapshotHelmReleases", func() {
+	It("should group the release secrets by release", func() {
+		clientset := fake.NewSimpleClientset(
+			fakeReleaseSecret("memcached-sample00", 1, "superseded", 100),
+			fakeReleaseSecret("memcached-sample00", 2, "deployed", 150),
+			fakeReleaseSecret("memcached-sample01", 1, "deployed", 100),
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: Namespace}, Data: map[string][]byte{"a": make([]byte, 10)}},
+		)
+		timeline := NewTimeline()
+		timeline.Mark(PhaseSteady)
+
+		snapshot, err := SnapshotHelmReleases(context.TODO(), clientset, timeline, Namespace)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshot.Phase).To(Equal(PhaseSteady))
+		Expect(snapshot.Secrets).To(Equal(3))
+		Expect(snapshot.Bytes).To(Equal(350))
+		Expect(snapshot.Releases).To(Equal([]HelmRelease{
+			{Namespace: Namespace, Name: "memcached-sample00", Secrets: 2, Bytes: 250, Revisions: []int{1, 2}, Status: "deployed"},
+			{Namespace: Namespace, Name: "memcached-sample01", Secrets: 1, Bytes: 100, Revisions: []int{1}, Status: "deployed"},
+		}))
+		sample01 := types.NamespacedName{Namespace: Namespace, Name: "memcached-sample01"}
+		Expect(snapshot.Leftover([]types.NamespacedName{sample01, {Namespace: Namespace, Name: "memcached-sample02"}})).
+			To(Equal([]types.NamespacedName{sample01}))
+	})
+
+	It("should keep releases of the same name in different namespaces apart", func() {
+		clientset := fake.NewSimpleClientset(
+			fakeReleaseSecretIn("perf-ns-0", "memcached-sample00", 1, "superseded", 100),
+			fakeReleaseSecretIn("perf-ns-0", "memcached-sample00", 2, "deployed", 150),
+			fakeReleaseSecretIn("perf-ns-1", "memcached-sample00", 3, "uninstalling", 50),
+		)
+
+		snapshot, err := SnapshotHelmReleases(context.TODO(), clientset, NewTimeline(), "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshot.Secrets).To(Equal(3))
+		Expect(snapshot.Releases).To(Equal([]HelmRelease{
+			{Namespace: "perf-ns-0", Name: "memcached-sample00", Secrets: 2, Bytes: 250, Revisions: []int{1, 2}, Status: "deployed"},
+			{Namespace: "perf-ns-1", Name: "memcached-sample00", Secrets: 1, Bytes: 50, Revisions: []int{3}, Status: "uninstalling"},
+		}))
+
+		leftover := types.NamespacedName{Namespace: "perf-ns-1", Name: "memcached-sample00"}
+		Expect(snapshot.Leftover([]types.NamespacedName{leftover, {Namespace: "perf-ns-2", Name: "memcached-sample00"}})).
+			To(Equal([]types.NamespacedName{leftover}))
+	})
+})
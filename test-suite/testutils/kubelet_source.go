@@ -0,0 +1,271 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// kubeletSummary Subset of the kubelet /stats/summary response used by the suite
+type kubeletSummary struct {
+	Node kubeletNodeStats  `json:"node"`
+	Pods []kubeletPodStats `json:"pods"`
+}
+
+type kubeletNodeStats struct {
+	NodeName string               `json:"nodeName"`
+	CPU      *kubeletCPUStats     `json:"cpu,omitempty"`
+	Memory   *kubeletMemoryStats  `json:"memory,omitempty"`
+	Network  *kubeletNetworkStats `json:"network,omitempty"`
+	Fs       *kubeletFsStats      `json:"fs,omitempty"`
+}
+
+type kubeletPodStats struct {
+	PodRef           kubeletPodReference     `json:"podRef"`
+	Containers       []kubeletContainerStats `json:"containers"`
+	Memory           *kubeletMemoryStats     `json:"memory,omitempty"`
+	Network          *kubeletNetworkStats    `json:"network,omitempty"`
+	EphemeralStorage *kubeletFsStats         `json:"ephemeral-storage,omitempty"`
+}
+
+type kubeletPodReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+type kubeletContainerStats struct {
+	Name   string              `json:"name"`
+	CPU    *kubeletCPUStats    `json:"cpu,omitempty"`
+	Memory *kubeletMemoryStats `json:"memory,omitempty"`
+	Rootfs *kubeletFsStats     `json:"rootfs,omitempty"`
+}
+
+type kubeletCPUStats struct {
+	Time           metav1.Time `json:"time"`
+	UsageNanoCores *uint64     `json:"usageNanoCores,omitempty"`
+}
+
+type kubeletMemoryStats struct {
+	Time            metav1.Time `json:"time"`
+	WorkingSetBytes *uint64     `json:"workingSetBytes,omitempty"`
+	RSSBytes        *uint64     `json:"rssBytes,omitempty"`
+	PageFaults      *uint64     `json:"pageFaults,omitempty"`
+	MajorPageFaults *uint64     `json:"majorPageFaults,omitempty"`
+}
+
+type kubeletNetworkStats struct {
+	RxBytes *uint64 `json:"rxBytes,omitempty"`
+	TxBytes *uint64 `json:"txBytes,omitempty"`
+}
+
+type kubeletFsStats struct {
+	UsedBytes *uint64 `json:"usedBytes,omitempty"`
+}
+
+// KubeletSource Gather metrics from the kubelet summary API through the API server node proxy
+type KubeletSource struct {
+	httpClient *http.Client
+	host       string
+}
+
+// NewKubeletSource create a metrics source using an HTTP client authenticated against the API server at host
+func NewKubeletSource(httpClient *http.Client, host string) *KubeletSource {
+	return &KubeletSource{
+		httpClient: httpClient,
+		host:       strings.TrimSuffix(host, "/"),
+	}
+}
+
+// PodMetrics get the metrics of the pods selected by the target from the summary of the nodes they run on, the pods of
+// all namespaces if the target has none
+func (k *KubeletSource) PodMetrics(ctx context.Context, target SampleTarget) ([]PodSample, error) {
+	pods := corev1.PodList{}
+	path := fmt.Sprintf("/api/v1/pods?labelSelector=%s", url.QueryEscape(target.LabelSelector))
+	if target.Namespace != "" {
+		path = fmt.Sprintf("/api/v1/namespaces/%s/pods?labelSelector=%s", target.Namespace, url.QueryEscape(target.LabelSelector))
+	}
+	if err := getJSON(ctx, k.httpClient, k.host+path, &pods); err != nil {
+		return nil, err
+	}
+
+	podsByNode := map[string]map[string]corev1.Pod{}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		if podsByNode[pod.Spec.NodeName] == nil {
+			podsByNode[pod.Spec.NodeName] = map[string]corev1.Pod{}
+		}
+		podsByNode[pod.Spec.NodeName][fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)] = pod
+	}
+
+	var samples []PodSample
+	for node, nodePods := range podsByNode {
+		summary, err := k.summary(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, podStats := range summary.Pods {
+			pod, ok := nodePods[fmt.Sprintf("%s/%s", podStats.PodRef.Namespace, podStats.PodRef.Name)]
+			if !ok {
+				continue
+			}
+			samples = append(samples, podSampleFromStats(pod, podStats))
+		}
+	}
+
+	return samples, nil
+}
+
+// NodeMetrics get the metrics of all nodes from their summary
+func (k *KubeletSource) NodeMetrics(ctx context.Context) ([]NodeSample, error) {
+	nodes := corev1.NodeList{}
+	if err := getJSON(ctx, k.httpClient, k.host+"/api/v1/nodes", &nodes); err != nil {
+		return nil, err
+	}
+
+	samples := make([]NodeSample, 0, len(nodes.Items))
+	for _, node := range nodes.Items {
+		summary, err := k.summary(ctx, node.Name)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, nodeSampleFromStats(node, summary.Node))
+	}
+
+	return samples, nil
+}
+
+// summary get the summary of a node through the API server node proxy
+func (k *KubeletSource) summary(ctx context.Context, node string) (kubeletSummary, error) {
+	summary := kubeletSummary{}
+	err := getJSON(ctx, k.httpClient, fmt.Sprintf("%s/api/v1/nodes/%s/proxy/stats/summary", k.host, node), &summary)
+
+	return summary, err
+}
+
+// podSampleFromStats convert the kubelet stats of a pod to a sample in the same shape as metrics-server
+func podSampleFromStats(pod corev1.Pod, podStats kubeletPodStats) PodSample {
+	sample := PodSample{
+		PodMetrics: v1beta1.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              pod.Name,
+				Namespace:         pod.Namespace,
+				Labels:            pod.Labels,
+				CreationTimestamp: metav1.Now(),
+			},
+		},
+		Stats: &UsageStats{},
+	}
+
+	for _, container := range podStats.Containers {
+		if container.CPU == nil || container.CPU.UsageNanoCores == nil || container.Memory == nil || container.Memory.WorkingSetBytes == nil {
+			continue
+		}
+		if container.CPU.Time.After(sample.Timestamp.Time) {
+			sample.Timestamp = container.CPU.Time
+		}
+
+		sample.Containers = append(sample.Containers, v1beta1.ContainerMetrics{
+			Name: container.Name,
+			Usage: corev1.ResourceList{
+				corev1.ResourceCPU:    *resource.NewScaledQuantity(int64(*container.CPU.UsageNanoCores), resource.Nano),
+				corev1.ResourceMemory: *resource.NewQuantity(int64(*container.Memory.WorkingSetBytes), resource.BinarySI),
+			},
+		})
+
+		containerStats := UsageStats{
+			Name:            container.Name,
+			RSSBytes:        container.Memory.RSSBytes,
+			PageFaults:      container.Memory.PageFaults,
+			MajorPageFaults: container.Memory.MajorPageFaults,
+		}
+		if container.Rootfs != nil {
+			containerStats.EphemeralStorageBytes = container.Rootfs.UsedBytes
+		}
+		sample.ContainerStats = append(sample.ContainerStats, containerStats)
+	}
+	sortContainersByName(sample.Containers)
+
+	if podStats.Memory != nil {
+		sample.Stats.RSSBytes = podStats.Memory.RSSBytes
+		sample.Stats.PageFaults = podStats.Memory.PageFaults
+		sample.Stats.MajorPageFaults = podStats.Memory.MajorPageFaults
+	}
+	if podStats.Network != nil {
+		sample.Stats.NetworkRxBytes = podStats.Network.RxBytes
+		sample.Stats.NetworkTxBytes = podStats.Network.TxBytes
+	}
+	if podStats.EphemeralStorage != nil {
+		sample.Stats.EphemeralStorageBytes = podStats.EphemeralStorage.UsedBytes
+	}
+
+	return sample
+}
+
+// nodeSampleFromStats convert the kubelet stats of a node to a sample in the same shape as metrics-server
+func nodeSampleFromStats(node corev1.Node, nodeStats kubeletNodeStats) NodeSample {
+	sample := NodeSample{
+		NodeMetrics: v1beta1.NodeMetrics{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              node.Name,
+				Labels:            node.Labels,
+				CreationTimestamp: metav1.Now(),
+			},
+			Usage: corev1.ResourceList{},
+		},
+		Stats: &UsageStats{},
+	}
+
+	if nodeStats.CPU != nil && nodeStats.CPU.UsageNanoCores != nil {
+		sample.Timestamp = nodeStats.CPU.Time
+		sample.Usage[corev1.ResourceCPU] = *resource.NewScaledQuantity(int64(*nodeStats.CPU.UsageNanoCores), resource.Nano)
+	}
+	if nodeStats.Memory != nil {
+		if nodeStats.Memory.WorkingSetBytes != nil {
+			sample.Usage[corev1.ResourceMemory] = *resource.NewQuantity(int64(*nodeStats.Memory.WorkingSetBytes), resource.BinarySI)
+		}
+		sample.Stats.RSSBytes = nodeStats.Memory.RSSBytes
+		sample.Stats.PageFaults = nodeStats.Memory.PageFaults
+		sample.Stats.MajorPageFaults = nodeStats.Memory.MajorPageFaults
+	}
+	if nodeStats.Network != nil {
+		sample.Stats.NetworkRxBytes = nodeStats.Network.RxBytes
+		sample.Stats.NetworkTxBytes = nodeStats.Network.TxBytes
+	}
+	if nodeStats.Fs != nil {
+		sample.Stats.EphemeralStorageBytes = nodeStats.Fs.UsedBytes
+	}
+
+	return sample
+}
+
+// getJSON GET the url and decode the JSON response body into object
+func getJSON(ctx context.Context, httpClient *http.Client, url string, object interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GET %s: %s: %s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(object)
+}
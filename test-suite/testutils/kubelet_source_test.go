@@ -0,0 +1,117 @@
+package testutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	fakeNodes = `{"items":[{"metadata":{"name":"kind-control-plane"}}]}`
+	fakePods  = `{"items":[{"metadata":{"name":"manager-pod","namespace":"memcached-operator-system",
+"labels":{"control-plane":"controller-manager"}},"spec":{"nodeName":"kind-control-plane"}}]}`
+	fakeSummary = `{
+  "node": {"nodeName": "kind-control-plane",
+    "cpu": {"time": "2022-08-01T10:00:00Z", "usageNanoCores": 500000000},
+    "memory": {"time": "2022-08-01T10:00:00Z", "workingSetBytes": 1073741824, "rssBytes": 536870912},
+    "network": {"rxBytes": 100, "txBytes": 200},
+    "fs": {"usedBytes": 300}},
+  "pods": [
+    {"podRef": {"name": "other-pod", "namespace": "kube-system"}, "containers": []},
+    {"podRef": {"name": "manager-pod", "namespace": "memcached-operator-system"},
+      "containers": [
+        {"name": "manager", "cpu": {"time": "2022-08-01T10:00:01Z", "usageNanoCores": 1500000},
+          "memory": {"time": "2022-08-01T10:00:01Z", "workingSetBytes": 20971520, "rssBytes": 10485760, "pageFaults": 42, "majorPageFaults": 1},
+          "rootfs": {"usedBytes": 4096}},
+        {"name": "kube-rbac-proxy", "cpu": {"time": "2022-08-01T10:00:00Z", "usageNanoCores": 100000},
+          "memory": {"time": "2022-08-01T10:00:00Z", "workingSetBytes": 8388608}}
+      ],
+      "memory": {"rssBytes": 18874368, "pageFaults": 50},
+      "network": {"rxBytes": 1024, "txBytes": 2048},
+      "ephemeral-storage": {"usedBytes": 8192}}
+  ]
+}`
+)
+
+var _ = Describe("KubeletSource", func() {
+	var (
+		server *httptest.Server
+		source *KubeletSource
+	)
+
+	BeforeEach(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/v1/nodes", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(fakeNodes))
+		})
+		mux.HandleFunc("/api/v1/namespaces/memcached-operator-system/pods", func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Query().Get("labelSelector")).To(Equal(OperatorPodLabel))
+			_, _ = w.Write([]byte(fakePods))
+		})
+		mux.HandleFunc("/api/v1/pods", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(fakePods))
+		})
+		mux.HandleFunc("/api/v1/nodes/kind-control-plane/proxy/stats/summary", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(fakeSummary))
+		})
+		server = httptest.NewServer(mux)
+		source = NewKubeletSource(server.Client(), server.URL+"/")
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should convert the summary of selected pods to samples", func() {
+		samples, err := source.PodMetrics(context.TODO(), SampleTarget{Namespace: Namespace, LabelSelector: OperatorPodLabel})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(samples).To(HaveLen(1))
+
+		sample := samples[0]
+		Expect(sample.Name).To(Equal("manager-pod"))
+		Expect(sample.Timestamp.UTC().Format("15:04:05")).To(Equal("10:00:01"))
+		Expect(sample.Containers).To(HaveLen(2))
+		Expect(sample.Containers[0].Name).To(Equal("kube-rbac-proxy"))
+		Expect(sample.Containers[1].Name).To(Equal("manager"))
+		Expect(sample.Containers[1].Usage.Cpu().String()).To(Equal("1500u"))
+		Expect(sample.Containers[1].Usage.Memory().String()).To(Equal("20Mi"))
+
+		Expect(*sample.Stats.RSSBytes).To(BeEquivalentTo(18874368))
+		Expect(*sample.Stats.NetworkRxBytes).To(BeEquivalentTo(1024))
+		Expect(*sample.Stats.NetworkTxBytes).To(BeEquivalentTo(2048))
+		Expect(*sample.Stats.EphemeralStorageBytes).To(BeEquivalentTo(8192))
+		Expect(sample.ContainerStats[0].Name).To(Equal("manager"))
+		Expect(*sample.ContainerStats[0].PageFaults).To(BeEquivalentTo(42))
+		Expect(*sample.ContainerStats[0].EphemeralStorageBytes).To(BeEquivalentTo(4096))
+	})
+
+	It("should select pods from all namespaces when the target has none", func() {
+		samples, err := source.PodMetrics(context.TODO(), SampleTarget{LabelSelector: OperatorPodLabel})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(samples).To(HaveLen(1))
+		Expect(samples[0].Namespace).To(Equal(Namespace))
+		Expect(samples[0].Name).To(Equal("manager-pod"))
+	})
+
+	It("should convert the summary of all nodes to samples", func() {
+		samples, err := source.NodeMetrics(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(samples).To(HaveLen(1))
+
+		sample := samples[0]
+		Expect(sample.Name).To(Equal("kind-control-plane"))
+		Expect(sample.Usage.Cpu().String()).To(Equal("500m"))
+		Expect(sample.Usage.Memory().String()).To(Equal("1Gi"))
+		Expect(*sample.Stats.RSSBytes).To(BeEquivalentTo(536870912))
+		Expect(*sample.Stats.EphemeralStorageBytes).To(BeEquivalentTo(300))
+	})
+
+	It("should return an error when the kubelet cannot be reached", func() {
+		server.Close()
+		_, err := source.NodeMetrics(context.TODO())
+		Expect(err).To(HaveOccurred())
+	})
+})
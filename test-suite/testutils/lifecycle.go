@@ -0,0 +1,695 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CRLifecycle When each step of the life of a CR was observed, in milliseconds since the start of the run.
+// Steps which were not observed are left out
+type CRLifecycle struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Requested is when the test started creating the CR
+	Requested *int64 `json:"requested,omitempty"`
+	// Created is when the CR was first seen
+	Created *int64 `json:"created,omitempty"`
+	// OwnedCreated is when the first Deployment or StatefulSet owned by the CR was seen
+	OwnedCreated *int64 `json:"ownedCreated,omitempty"`
+	// PodsScheduled and ContainersReady are when as many pods as the owned objects want replicas were scheduled and ready
+	PodsScheduled   *int64 `json:"podsScheduled,omitempty"`
+	ContainersReady *int64 `json:"containersReady,omitempty"`
+	// StatusUpdated is when the CR was first seen with a status
+	StatusUpdated *int64 `json:"statusUpdated,omitempty"`
+	// OperatorReady is when the status of the CR first said it was ready, which can be before or after its pods are
+	OperatorReady *int64 `json:"operatorReady,omitempty"`
+	// DeleteRequested is when the test started deleting the CR
+	DeleteRequested *int64 `json:"deleteRequested,omitempty"`
+	// DeletionStarted is when the CR was first seen marked for deletion while its finalizers held it, and
+	// FinalizersRemoved is when the operator was seen removing the last of them. Both are left out for CRs without
+	// finalizers, which are deleted straight away
+	DeletionStarted   *int64 `json:"deletionStarted,omitempty"`
+	FinalizersRemoved *int64 `json:"finalizersRemoved,omitempty"`
+	// Deleted is when the CR was seen deleted
+	Deleted *int64 `json:"deleted,omitempty"`
+	// WorkloadsGone is when the last Deployment, StatefulSet or ReplicaSet owned by the CR was garbage collected
+	WorkloadsGone *int64 `json:"workloadsGone,omitempty"`
+	// PodsGone is when the last pod owned by the CR terminated
+	PodsGone *int64 `json:"podsGone,omitempty"`
+	// OwnedGone is when the last object owned by the CR, directly or through its Deployments and StatefulSets, was seen deleted
+	OwnedGone *int64 `json:"ownedGone,omitempty"`
+}
+
+// LatencyPercentiles Percentiles of a latency over every CR or request it was observed for, in milliseconds
+type LatencyPercentiles struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// trackedObject The times an object was observed at and what owns it
+type trackedObject struct {
+	kind     string
+	name     string
+	owners   []types.UID
+	replicas int32
+	// readyReplicas and rolledOut are the status of a Deployment or StatefulSet, rolledOut is set once the controller
+	// has seen the latest spec
+	readyReplicas int32
+	rolledOut     bool
+
+	// readyAt is when the containers of a pod were ready, or when the operator reported a CR ready
+	seenAt      time.Time
+	scheduledAt time.Time
+	readyAt     time.Time
+	statusAt    time.Time
+	goneAt      time.Time
+
+	// finalized is set once the object was seen with finalizers
+	finalized           bool
+	deletingAt          time.Time
+	finalizersRemovedAt time.Time
+}
+
+// trackedCR A CR being tracked until it and every object it owned are gone
+type trackedCR struct {
+	key               types.NamespacedName
+	requestedAt       time.Time
+	deleteRequestedAt time.Time
+}
+
+// lastGone When the last of a group of objects was seen deleted
+type lastGone struct {
+	at        time.Time
+	objects   int
+	remaining int
+}
+
+// LifecycleTracker Watch CRs and the Deployments, StatefulSets, ReplicaSets and Pods in a namespace with informers,
+// recording when each object was observed at every step so the lifecycle of each CR can be worked out from the objects
+// it owns. Once a CR and everything it owned are gone its lifecycle is recorded and their objects are dropped
+type LifecycleTracker struct {
+	clientset     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	gvr           schema.GroupVersionResource
+	ready         ReadinessPredicate
+	timeline      *Timeline
+	namespace     string
+
+	mu      sync.Mutex
+	objects map[types.UID]*trackedObject
+	// children indexes the objects by the UID of each of their owners
+	children map[types.UID]map[types.UID]struct{}
+	// crs are the CRs being tracked and current the UID of the last CR seen with each name, a CR can be re-created
+	// with the same name before the objects owned by the previous one are gone
+	crs     map[types.UID]*trackedCR
+	current map[types.NamespacedName]types.UID
+	// requested and deleteRequested are the CRs asked to be created or deleted before they were seen
+	requested       map[types.NamespacedName]time.Time
+	deleteRequested map[types.NamespacedName]time.Time
+	finished        []CRLifecycle
+	scales          map[types.NamespacedName][]*crScale
+	stopCh          chan struct{}
+}
+
+// NewLifecycleTracker create a tracker for the CRs of gvr in namespace, which are ready once ready is true for them
+func NewLifecycleTracker(clientset kubernetes.Interface, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource,
+	ready ReadinessPredicate, timeline *Timeline, namespace string) *LifecycleTracker {
+	return &LifecycleTracker{
+		clientset:       clientset,
+		dynamicClient:   dynamicClient,
+		gvr:             gvr,
+		ready:           ready,
+		timeline:        timeline,
+		namespace:       namespace,
+		objects:         map[types.UID]*trackedObject{},
+		children:        map[types.UID]map[types.UID]struct{}{},
+		crs:             map[types.UID]*trackedCR{},
+		current:         map[types.NamespacedName]types.UID{},
+		requested:       map[types.NamespacedName]time.Time{},
+		deleteRequested: map[types.NamespacedName]time.Time{},
+		scales:          map[types.NamespacedName][]*crScale{},
+	}
+}
+
+// Start the informers and wait for them to sync
+func (l *LifecycleTracker) Start(ctx context.Context) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(l.clientset, 0, informers.WithNamespace(l.namespace))
+	dynamicFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(l.dynamicClient, 0, l.namespace, nil)
+	for _, informer := range []cache.SharedIndexInformer{
+		factory.Apps().V1().Deployments().Informer(),
+		factory.Apps().V1().StatefulSets().Informer(),
+		factory.Apps().V1().ReplicaSets().Informer(),
+		factory.Core().V1().Pods().Informer(),
+		dynamicFactory.ForResource(l.gvr).Informer(),
+	} {
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: l.observe,
+			UpdateFunc: func(_, obj interface{}) {
+				l.observe(obj)
+			},
+			DeleteFunc: func(obj interface{}) {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				l.gone(obj)
+			},
+		})
+	}
+
+	stopCh := make(chan struct{})
+	l.mu.Lock()
+	l.stopCh = stopCh
+	l.mu.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.Stop()
+		case <-stopCh:
+		}
+	}()
+	factory.Start(stopCh)
+	dynamicFactory.Start(stopCh)
+	for informerType, synced := range factory.WaitForCacheSync(stopCh) {
+		if !synced {
+			return fmt.Errorf("failed to sync informer for %v", informerType)
+		}
+	}
+	for gvr, synced := range dynamicFactory.WaitForCacheSync(stopCh) {
+		if !synced {
+			return fmt.Errorf("failed to sync informer for %v", gvr)
+		}
+	}
+
+	return nil
+}
+
+// Stop the informers
+func (l *LifecycleTracker) Stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stopCh != nil {
+		close(l.stopCh)
+		l.stopCh = nil
+	}
+}
+
+// Requested record when the test started creating the CR
+func (l *LifecycleTracker) Requested(key types.NamespacedName, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.requested[key] = at
+}
+
+// CreateFailed drop the request of a CR which could not be created, so it is not reported as a CR which never became
+// ready. A CR which was seen anyway, such as when the response to a create which succeeded was lost, is kept
+func (l *LifecycleTracker) CreateFailed(key types.NamespacedName) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.requested, key)
+}
+
+// DeleteRequested record when the test started deleting the CR
+func (l *LifecycleTracker) DeleteRequested(key types.NamespacedName, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if uid, ok := l.current[key]; ok {
+		l.crs[uid].deleteRequestedAt = at
+		return
+	}
+	l.deleteRequested[key] = at
+}
+
+// observe record the steps an added or updated object has reached
+func (l *LifecycleTracker) observe(obj interface{}) {
+	now := time.Now()
+	meta, ok := obj.(metav1.Object)
+	if !ok {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	object := l.object(meta, now)
+	l.observeFinalizers(object, meta, now)
+	switch o := obj.(type) {
+	case *unstructured.Unstructured:
+		object.kind = o.GetKind()
+		l.observeCR(o)
+		if status, found, _ := unstructured.NestedMap(o.Object, "status"); found && len(status) > 0 && object.statusAt.IsZero() {
+			object.statusAt = now
+		}
+		if object.readyAt.IsZero() && l.ready(o) {
+			object.readyAt = now
+		}
+	case *appsv1.Deployment:
+		object.kind = "Deployment"
+		object.replicas = replicasOf(o.Spec.Replicas)
+		object.readyReplicas = o.Status.ReadyReplicas
+		object.rolledOut = o.Status.ObservedGeneration >= o.Generation
+		l.checkScales(object, now)
+	case *appsv1.StatefulSet:
+		object.kind = "StatefulSet"
+		object.replicas = replicasOf(o.Spec.Replicas)
+		object.readyReplicas = o.Status.ReadyReplicas
+		object.rolledOut = o.Status.ObservedGeneration >= o.Generation
+		l.checkScales(object, now)
+	case *appsv1.ReplicaSet:
+		object.kind = "ReplicaSet"
+	case *corev1.Pod:
+		object.kind = "Pod"
+		for _, condition := range o.Status.Conditions {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			if condition.Type == corev1.PodScheduled && object.scheduledAt.IsZero() {
+				object.scheduledAt = now
+			}
+			if condition.Type == corev1.ContainersReady && object.readyAt.IsZero() {
+				object.readyAt = now
+			}
+		}
+	}
+}
+
+// gone record the deletion of an object
+func (l *LifecycleTracker) gone(obj interface{}) {
+	now := time.Now()
+	meta, ok := obj.(metav1.Object)
+	if !ok {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Objects already dropped, or deleted before they were seen, have nothing left to record
+	if _, ok := l.objects[meta.GetUID()]; !ok {
+		return
+	}
+	object := l.object(meta, now)
+	// Removing the last finalizer deletes the object in the same request, so it is usually only seen gone
+	if object.finalized && object.finalizersRemovedAt.IsZero() {
+		object.finalizersRemovedAt = now
+	}
+	if object.goneAt.IsZero() {
+		object.goneAt = now
+	}
+	l.release(meta.GetUID())
+}
+
+// observeCR start tracking a CR the first time it is seen, must be called with the lock held
+func (l *LifecycleTracker) observeCR(cr *unstructured.Unstructured) {
+	if _, ok := l.crs[cr.GetUID()]; ok {
+		return
+	}
+
+	key := types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}
+	l.crs[cr.GetUID()] = &trackedCR{key: key, requestedAt: l.requested[key], deleteRequestedAt: l.deleteRequested[key]}
+	l.current[key] = cr.GetUID()
+	delete(l.requested, key)
+	delete(l.deleteRequested, key)
+}
+
+// release drop an object which is gone unless the lifecycle of a CR owning it still needs it, recording the lifecycle
+// of the CR once it and everything it owned are gone, must be called with the lock held
+func (l *LifecycleTracker) release(uid types.UID) {
+	crUID, ok := l.ownerCR(uid)
+	if !ok {
+		l.drop(uid)
+		return
+	}
+
+	if cr := l.objects[crUID]; cr == nil || cr.goneAt.IsZero() {
+		return
+	}
+	owned := l.descendants(crUID)
+	for _, ownedUID := range owned {
+		if l.objects[ownedUID].goneAt.IsZero() {
+			return
+		}
+	}
+
+	l.finished = append(l.finished, l.lifecycle(crUID))
+	for _, ownedUID := range owned {
+		l.drop(ownedUID)
+	}
+	l.drop(crUID)
+	key := l.crs[crUID].key
+	if l.current[key] == crUID {
+		delete(l.current, key)
+	}
+	delete(l.crs, crUID)
+}
+
+// drop stop tracking an object, must be called with the lock held
+func (l *LifecycleTracker) drop(uid types.UID) {
+	object, ok := l.objects[uid]
+	if !ok {
+		return
+	}
+	l.unindex(uid, object.owners)
+	delete(l.objects, uid)
+}
+
+// unindex remove an object from the children of its owners, must be called with the lock held
+func (l *LifecycleTracker) unindex(uid types.UID, owners []types.UID) {
+	for _, owner := range owners {
+		delete(l.children[owner], uid)
+		if len(l.children[owner]) == 0 {
+			delete(l.children, owner)
+		}
+	}
+}
+
+// observeFinalizers record when an object was marked for deletion and when its finalizers were removed, must be called
+// with the lock held
+func (l *LifecycleTracker) observeFinalizers(object *trackedObject, meta metav1.Object, now time.Time) {
+	finalizers := len(meta.GetFinalizers())
+	if finalizers > 0 {
+		object.finalized = true
+	}
+	if meta.GetDeletionTimestamp() == nil {
+		return
+	}
+	if object.deletingAt.IsZero() {
+		object.deletingAt = now
+	}
+	if finalizers == 0 && object.finalized && object.finalizersRemovedAt.IsZero() {
+		object.finalizersRemovedAt = now
+	}
+}
+
+// object get the tracked object, tracking it if it was not seen before, must be called with the lock held
+func (l *LifecycleTracker) object(meta metav1.Object, now time.Time) *trackedObject {
+	uid := meta.GetUID()
+	object, ok := l.objects[uid]
+	if !ok {
+		object = &trackedObject{name: meta.GetName(), seenAt: now}
+		l.objects[uid] = object
+	}
+	l.unindex(uid, object.owners)
+	object.owners = object.owners[:0]
+	for _, owner := range meta.GetOwnerReferences() {
+		object.owners = append(object.owners, owner.UID)
+		if l.children[owner.UID] == nil {
+			l.children[owner.UID] = map[types.UID]struct{}{}
+		}
+		l.children[owner.UID][uid] = struct{}{}
+	}
+
+	return object
+}
+
+// Lifecycles get the lifecycle of every CR seen or requested, sorted by namespace and name, and a CR re-created with
+// the same name after the previous one
+func (l *LifecycleTracker) Lifecycles() []CRLifecycle {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lifecycles := make([]CRLifecycle, 0, len(l.finished)+len(l.crs)+len(l.requested))
+	lifecycles = append(lifecycles, l.finished...)
+	for uid := range l.crs {
+		lifecycles = append(lifecycles, l.lifecycle(uid))
+	}
+	for key, at := range l.requested {
+		lifecycles = append(lifecycles, CRLifecycle{Namespace: key.Namespace, Name: key.Name, Requested: l.offset(at),
+			DeleteRequested: l.offset(l.deleteRequested[key])})
+	}
+	sort.SliceStable(lifecycles, func(i, j int) bool {
+		if lifecycles[i].Namespace != lifecycles[j].Namespace {
+			return lifecycles[i].Namespace < lifecycles[j].Namespace
+		}
+		if lifecycles[i].Name != lifecycles[j].Name {
+			return lifecycles[i].Name < lifecycles[j].Name
+		}
+		return lifecycleStart(lifecycles[i]) < lifecycleStart(lifecycles[j])
+	})
+
+	return lifecycles
+}
+
+// lifecycleStart get when a CR was requested, or created if it was not requested through the tracker
+func lifecycleStart(lifecycle CRLifecycle) int64 {
+	switch {
+	case lifecycle.Requested != nil:
+		return *lifecycle.Requested
+	case lifecycle.Created != nil:
+		return *lifecycle.Created
+	}
+
+	return 0
+}
+
+// lifecycle work out the lifecycle of a tracked CR from the objects it owns, must be called with the lock held
+func (l *LifecycleTracker) lifecycle(uid types.UID) CRLifecycle {
+	tracked := l.crs[uid]
+	lifecycle := CRLifecycle{
+		Namespace:       tracked.key.Namespace,
+		Name:            tracked.key.Name,
+		Requested:       l.offset(tracked.requestedAt),
+		DeleteRequested: l.offset(tracked.deleteRequestedAt),
+	}
+	cr := l.objects[uid]
+	lifecycle.Created = l.offset(cr.seenAt)
+	lifecycle.StatusUpdated = l.offset(cr.statusAt)
+	lifecycle.OperatorReady = l.offset(cr.readyAt)
+	lifecycle.Deleted = l.offset(cr.goneAt)
+	if cr.finalized {
+		lifecycle.DeletionStarted = l.offset(cr.deletingAt)
+		lifecycle.FinalizersRemoved = l.offset(cr.finalizersRemovedAt)
+	}
+
+	var (
+		ownedCreated time.Time
+		replicas     int32
+		scheduled    []time.Time
+		ready        []time.Time
+		ownedGone    lastGone
+		workloadGone lastGone
+		podGone      lastGone
+	)
+	for _, ownedUID := range l.descendants(uid) {
+		object := l.objects[ownedUID]
+		ownedGone.add(object)
+		if object.kind == "Pod" {
+			podGone.add(object)
+		} else {
+			workloadGone.add(object)
+		}
+
+		switch object.kind {
+		case "Deployment", "StatefulSet":
+			if !containsUID(object.owners, uid) {
+				continue
+			}
+			if ownedCreated.IsZero() || object.seenAt.Before(ownedCreated) {
+				ownedCreated = object.seenAt
+			}
+			replicas += object.replicas
+		case "Pod":
+			if !object.scheduledAt.IsZero() {
+				scheduled = append(scheduled, object.scheduledAt)
+			}
+			if !object.readyAt.IsZero() {
+				ready = append(ready, object.readyAt)
+			}
+		}
+	}
+	lifecycle.OwnedCreated = l.offset(ownedCreated)
+	lifecycle.PodsScheduled = l.offset(nthEarliest(scheduled, int(replicas)))
+	lifecycle.ContainersReady = l.offset(nthEarliest(ready, int(replicas)))
+	lifecycle.WorkloadsGone = l.offset(workloadGone.time())
+	lifecycle.PodsGone = l.offset(podGone.time())
+	lifecycle.OwnedGone = l.offset(ownedGone.time())
+
+	return lifecycle
+}
+
+// add an object to the group
+func (g *lastGone) add(object *trackedObject) {
+	g.objects++
+	if object.goneAt.IsZero() {
+		g.remaining++
+	} else if object.goneAt.After(g.at) {
+		g.at = object.goneAt
+	}
+}
+
+// time get when the last object of the group was seen deleted, zero if the group is empty or some are not deleted
+func (g *lastGone) time() time.Time {
+	if g.objects == 0 || g.remaining > 0 {
+		return time.Time{}
+	}
+
+	return g.at
+}
+
+// descendants get the UIDs of the objects owned by uid directly or through other owned objects, must be called with
+// the lock held
+func (l *LifecycleTracker) descendants(uid types.UID) []types.UID {
+	var owned []types.UID
+	seen := map[types.UID]struct{}{uid: {}}
+	owners := []types.UID{uid}
+	for len(owners) > 0 {
+		owner := owners[0]
+		owners = owners[1:]
+		for child := range l.children[owner] {
+			if _, ok := seen[child]; ok {
+				continue
+			}
+			seen[child] = struct{}{}
+			owned = append(owned, child)
+			owners = append(owners, child)
+		}
+	}
+
+	return owned
+}
+
+// ownerCR get the tracked CR which is the object or owns it directly or through its owners, must be called with the
+// lock held
+func (l *LifecycleTracker) ownerCR(uid types.UID) (types.UID, bool) {
+	// Ownership chains are short, Pod -> ReplicaSet -> Deployment -> CR, the depth limit guards against cycles
+	for depth := 0; depth < 5; depth++ {
+		if _, ok := l.crs[uid]; ok {
+			return uid, true
+		}
+		object, ok := l.objects[uid]
+		if !ok {
+			return "", false
+		}
+		next := types.UID("")
+		for _, owner := range object.owners {
+			if _, ok := l.crs[owner]; ok {
+				return owner, true
+			}
+			if _, ok := l.objects[owner]; ok && next == "" {
+				next = owner
+			}
+		}
+		if next == "" {
+			return "", false
+		}
+		uid = next
+	}
+
+	return "", false
+}
+
+// offset get the milliseconds since the start of the run of a time, nil if it is not set
+func (l *LifecycleTracker) offset(at time.Time) *int64 {
+	if at.IsZero() {
+		return nil
+	}
+	offset := at.Sub(l.timeline.Start()).Milliseconds()
+
+	return &offset
+}
+
+// CRLatencies get the percentiles over every CR of the time from it being requested, or created if it was not
+// requested through the tracker, to each later step, and from it being requested for deletion to it and the objects
+// it owns being gone. The deletion is also split into the time the operator took to remove its finalizers and the
+// time garbage collection took to delete what the CR owned after it was gone
+func CRLatencies(lifecycles []CRLifecycle) map[string]LatencyPercentiles {
+	latencies := map[string][]float64{}
+	add := func(name string, from, to *int64) {
+		if from == nil || to == nil {
+			return
+		}
+		latencies[name] = append(latencies[name], float64(*to-*from))
+	}
+
+	for _, lifecycle := range lifecycles {
+		start := lifecycle.Requested
+		if start == nil {
+			start = lifecycle.Created
+		} else {
+			add("created", start, lifecycle.Created)
+		}
+		add("ownedCreated", start, lifecycle.OwnedCreated)
+		add("podsScheduled", start, lifecycle.PodsScheduled)
+		add("containersReady", start, lifecycle.ContainersReady)
+		add("statusUpdated", start, lifecycle.StatusUpdated)
+		add("operatorReady", start, lifecycle.OperatorReady)
+		add("finalizersRemoved", lifecycle.DeleteRequested, lifecycle.FinalizersRemoved)
+		add("deleted", lifecycle.DeleteRequested, lifecycle.Deleted)
+		add("workloadsGone", lifecycle.DeleteRequested, lifecycle.WorkloadsGone)
+		add("podsGone", lifecycle.DeleteRequested, lifecycle.PodsGone)
+		add("ownedGone", lifecycle.DeleteRequested, lifecycle.OwnedGone)
+		add("operatorFinalize", lifecycle.DeletionStarted, lifecycle.FinalizersRemoved)
+		add("garbageCollection", lifecycle.Deleted, lifecycle.OwnedGone)
+	}
+
+	return latencyPercentiles(latencies)
+}
+
+// latencyPercentiles get the percentiles of each named set of latencies
+func latencyPercentiles(latencies map[string][]float64) map[string]LatencyPercentiles {
+	percentiles := map[string]LatencyPercentiles{}
+	for name, values := range latencies {
+		sort.Float64s(values)
+		percentiles[name] = LatencyPercentiles{
+			Count: len(values),
+			P50:   percentile(values, 0.5),
+			P90:   percentile(values, 0.9),
+			P99:   percentile(values, 0.99),
+			Max:   values[len(values)-1],
+		}
+	}
+
+	return percentiles
+}
+
+// replicasOf get the desired replicas of a workload, which default to 1
+func replicasOf(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+
+	return *replicas
+}
+
+// nthEarliest get the nth earliest of the times, zero if there are fewer than n or n is not positive
+func nthEarliest(times []time.Time, n int) time.Time {
+	if n <= 0 || len(times) < n {
+		return time.Time{}
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+
+	return times[n-1]
+}
+
+// containsUID check whether uid is one of uids
+func containsUID(uids []types.UID, uid types.UID) bool {
+	for _, u := range uids {
+		if u == uid {
+			return true
+		}
+	}
+
+	return false
+}
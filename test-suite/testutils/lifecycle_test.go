@@ -0,0 +1,251 @@
+package testutils
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	memcachedGVR = schema.GroupVersionResource{Group: "cache.example.com", Version: "v1alpha1", Resource: "memcacheds"}
+	sample00     = types.NamespacedName{Namespace: Namespace, Name: "memcached-sample00"}
+)
+
+// fakeMemcached create a memcached CR
+func fakeMemcached(name string, uid types.UID) *unstructured.Unstructured {
+	cr := &unstructured.Unstructured{}
+	cr.SetAPIVersion("cache.example.com/v1alpha1")
+	cr.SetKind("Memcached")
+	cr.SetNamespace(Namespace)
+	cr.SetName(name)
+	cr.SetUID(uid)
+
+	return cr
+}
+
+// ownedBy create object metadata of an object owned by uid
+func ownedBy(name string, uid, owner types.UID) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:            name,
+		Namespace:       Namespace,
+		UID:             uid,
+		OwnerReferences: []metav1.OwnerReference{{Name: "owner", UID: owner}},
+	}
+}
+
+func newFakeDynamicClient() *dynamicfake.FakeDynamicClient {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{memcachedGVR: "MemcachedList"})
+}
+
+var _ = Describe("LifecycleTracker", func() {
+	It("should record each step of the lifecycle of a CR from the objects it owns", func() {
+		ctx := context.TODO()
+		clientset := fake.NewSimpleClientset()
+		dynamicClient := newFakeDynamicClient()
+		timeline := NewTimeline()
+		tracker := NewLifecycleTracker(clientset, dynamicClient, memcachedGVR, StatusNodesReady, timeline, Namespace)
+		Expect(tracker.Start(ctx)).To(Succeed())
+		defer tracker.Stop()
+
+		step := func(f func() error) {
+			time.Sleep(5 * time.Millisecond)
+			Expect(f()).To(Succeed())
+		}
+		crs := dynamicClient.Resource(memcachedGVR).Namespace(Namespace)
+		replicas := int32(1)
+		deployment := &appsv1.Deployment{ObjectMeta: ownedBy("memcached-sample00", "deployment", "cr"),
+			Spec: appsv1.DeploymentSpec{Replicas: &replicas}}
+		pod := &corev1.Pod{ObjectMeta: ownedBy("memcached-sample00-abc", "pod", "replicaset")}
+
+		tracker.Requested(sample00, time.Now())
+		step(func() error {
+			_, err := crs.Create(ctx, fakeMemcached("memcached-sample00", "cr"), metav1.CreateOptions{})
+			return err
+		})
+		step(func() error {
+			_, err := clientset.AppsV1().Deployments(Namespace).Create(ctx, deployment, metav1.CreateOptions{})
+			return err
+		})
+		step(func() error {
+			_, err := clientset.AppsV1().ReplicaSets(Namespace).Create(ctx,
+				&appsv1.ReplicaSet{ObjectMeta: ownedBy("memcached-sample00-abc", "replicaset", "deployment")}, metav1.CreateOptions{})
+			return err
+		})
+		step(func() error {
+			_, err := clientset.CoreV1().Pods(Namespace).Create(ctx, pod, metav1.CreateOptions{})
+			return err
+		})
+		step(func() error {
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionTrue}}
+			_, err := clientset.CoreV1().Pods(Namespace).UpdateStatus(ctx, pod, metav1.UpdateOptions{})
+			return err
+		})
+		step(func() error {
+			pod.Status.Conditions = append(pod.Status.Conditions, corev1.PodCondition{Type: corev1.ContainersReady, Status: corev1.ConditionTrue})
+			_, err := clientset.CoreV1().Pods(Namespace).UpdateStatus(ctx, pod, metav1.UpdateOptions{})
+			return err
+		})
+		step(func() error {
+			cr := fakeMemcached("memcached-sample00", "cr")
+			cr.Object["status"] = map[string]interface{}{"nodes": []interface{}{"memcached-sample00-abc"}}
+			_, err := crs.Update(ctx, cr, metav1.UpdateOptions{})
+			return err
+		})
+		Eventually(func() *int64 {
+			return tracker.Lifecycles()[0].StatusUpdated
+		}).ShouldNot(BeNil())
+
+		tracker.DeleteRequested(sample00, time.Now())
+		step(func() error {
+			return crs.Delete(ctx, "memcached-sample00", metav1.DeleteOptions{})
+		})
+		for _, f := range []func() error{
+			func() error {
+				return clientset.AppsV1().Deployments(Namespace).Delete(ctx, "memcached-sample00", metav1.DeleteOptions{})
+			},
+			func() error {
+				return clientset.AppsV1().ReplicaSets(Namespace).Delete(ctx, "memcached-sample00-abc", metav1.DeleteOptions{})
+			},
+			func() error {
+				return clientset.CoreV1().Pods(Namespace).Delete(ctx, "memcached-sample00-abc", metav1.DeleteOptions{})
+			},
+		} {
+			step(f)
+		}
+		Eventually(func() *int64 {
+			return tracker.Lifecycles()[0].OwnedGone
+		}).ShouldNot(BeNil())
+
+		lifecycles := tracker.Lifecycles()
+		Expect(lifecycles).To(HaveLen(1))
+		lifecycle := lifecycles[0]
+		steps := []*int64{lifecycle.Requested, lifecycle.Created, lifecycle.OwnedCreated, lifecycle.PodsScheduled,
+			lifecycle.ContainersReady, lifecycle.StatusUpdated, lifecycle.OperatorReady, lifecycle.DeleteRequested,
+			lifecycle.Deleted, lifecycle.WorkloadsGone, lifecycle.PodsGone}
+		for i, step := range steps {
+			Expect(step).NotTo(BeNil(), "step %d", i)
+			if i > 0 {
+				Expect(*step).To(BeNumerically(">=", *steps[i-1]), "step %d", i)
+			}
+		}
+
+		Expect(lifecycle.OwnedGone).To(Equal(lifecycle.PodsGone))
+		Expect(lifecycle.DeletionStarted).To(BeNil())
+		Expect(lifecycle.FinalizersRemoved).To(BeNil())
+
+		latencies := CRLatencies(lifecycles)
+		Expect(latencies).To(HaveLen(11))
+		Expect(latencies["containersReady"].Count).To(Equal(1))
+		Expect(latencies["containersReady"].P50).To(Equal(float64(*lifecycle.ContainersReady - *lifecycle.Requested)))
+	})
+
+	It("should record when the finalizers of a CR were removed", func() {
+		ctx := context.TODO()
+		dynamicClient := newFakeDynamicClient()
+		timeline := NewTimeline()
+		tracker := NewLifecycleTracker(fake.NewSimpleClientset(), dynamicClient, memcachedGVR, StatusNodesReady, timeline, Namespace)
+		Expect(tracker.Start(ctx)).To(Succeed())
+		defer tracker.Stop()
+
+		crs := dynamicClient.Resource(memcachedGVR).Namespace(Namespace)
+		cr := fakeMemcached("memcached-sample00", "cr")
+		cr.SetFinalizers([]string{"helm.sdk.operatorframework.io/uninstall-release"})
+		_, err := crs.Create(ctx, cr, metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		// The fake client ignores finalizers, so marking the CR for deletion is done by hand
+		time.Sleep(5 * time.Millisecond)
+		tracker.DeleteRequested(sample00, time.Now())
+		now := metav1.Now()
+		cr.SetDeletionTimestamp(&now)
+		_, err = crs.Update(ctx, cr, metav1.UpdateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(func() *int64 {
+			return tracker.Lifecycles()[0].DeletionStarted
+		}).ShouldNot(BeNil())
+		Expect(tracker.Lifecycles()[0].FinalizersRemoved).To(BeNil())
+
+		time.Sleep(5 * time.Millisecond)
+		Expect(crs.Delete(ctx, "memcached-sample00", metav1.DeleteOptions{})).To(Succeed())
+		Eventually(func() *int64 {
+			return tracker.Lifecycles()[0].Deleted
+		}).ShouldNot(BeNil())
+
+		lifecycle := tracker.Lifecycles()[0]
+		Expect(*lifecycle.DeletionStarted).To(BeNumerically(">=", *lifecycle.DeleteRequested))
+		Expect(lifecycle.FinalizersRemoved).To(Equal(lifecycle.Deleted))
+		Expect(*lifecycle.FinalizersRemoved).To(BeNumerically(">", *lifecycle.DeletionStarted))
+		Expect(CRLatencies([]CRLifecycle{lifecycle})).To(HaveKey("operatorFinalize"))
+	})
+
+	It("should keep CRs with the same name apart and drop them once they and what they owned are gone", func() {
+		tracker := NewLifecycleTracker(fake.NewSimpleClientset(), newFakeDynamicClient(), memcachedGVR, StatusNodesReady,
+			NewTimeline(), metav1.NamespaceAll)
+		requested := time.Now()
+		other := types.NamespacedName{Namespace: "memcached-tenant-01", Name: "memcached-sample00"}
+		tracker.Requested(sample00, requested)
+		tracker.Requested(other, requested)
+
+		first := fakeMemcached("memcached-sample00", "first")
+		tracker.observe(first)
+		otherCR := fakeMemcached("memcached-sample00", "other")
+		otherCR.SetNamespace(other.Namespace)
+		tracker.observe(otherCR)
+		deployment := &appsv1.Deployment{ObjectMeta: ownedBy("memcached-sample00", "first-deployment", "first")}
+		tracker.observe(deployment)
+		pod := &corev1.Pod{ObjectMeta: ownedBy("memcached-sample00-abc", "first-pod", "first-deployment")}
+		tracker.observe(pod)
+
+		lifecycles := tracker.Lifecycles()
+		Expect(lifecycles).To(HaveLen(2))
+		Expect(lifecycles[0].Namespace).To(Equal(Namespace))
+		Expect(lifecycles[0].OwnedCreated).NotTo(BeNil())
+		Expect(lifecycles[1].Namespace).To(Equal(other.Namespace))
+		Expect(lifecycles[1].OwnedCreated).To(BeNil())
+
+		// The CR is re-created with the same name before the pod of the previous one is gone
+		tracker.DeleteRequested(sample00, time.Now())
+		tracker.gone(first)
+		tracker.gone(deployment)
+		tracker.Requested(sample00, requested.Add(time.Second))
+		tracker.observe(fakeMemcached("memcached-sample00", "second"))
+		Expect(tracker.Lifecycles()).To(HaveLen(3))
+		tracker.gone(pod)
+
+		lifecycles = tracker.Lifecycles()
+		Expect(lifecycles).To(HaveLen(3))
+		Expect(lifecycles[0].Deleted).NotTo(BeNil())
+		Expect(lifecycles[0].PodsGone).NotTo(BeNil())
+		Expect(lifecycles[1].Name).To(Equal(sample00.Name))
+		Expect(*lifecycles[1].Requested).To(BeNumerically(">", *lifecycles[0].Requested))
+		Expect(lifecycles[1].DeleteRequested).To(BeNil())
+		Expect(lifecycles[2].Namespace).To(Equal(other.Namespace))
+		// Only the CRs which still exist are kept
+		Expect(tracker.objects).To(HaveLen(2))
+		Expect(tracker.children).To(BeEmpty())
+	})
+
+	It("should report percentiles of each latency over the CRs", func() {
+		lifecycles := make([]CRLifecycle, 0, 10)
+		for i := int64(1); i <= 10; i++ {
+			created, ready := int64(1000), 1000+i*100
+			lifecycles = append(lifecycles, CRLifecycle{Created: &created, ContainersReady: &ready})
+		}
+
+		Expect(CRLatencies(lifecycles)).To(Equal(map[string]LatencyPercentiles{
+			"containersReady": {Count: 10, P50: 500, P90: 900, P99: 1000, Max: 1000},
+		}))
+	})
+})
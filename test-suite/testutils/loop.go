@@ -0,0 +1,45 @@
+package testutils
+
+import (
+	"context"
+	"time"
+)
+
+// backgroundLoop A function called on an interval in the background until stopped
+type backgroundLoop struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startLoop call fn every interval until the context is cancelled or the loop is stopped
+func startLoop(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) *backgroundLoop {
+	ctx, cancel := context.WithCancel(ctx)
+	loop := &backgroundLoop{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(loop.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fn(ctx)
+			}
+		}
+	}()
+
+	return loop
+}
+
+// stop the loop and wait for the current call to return. Stopping a nil or stopped loop does nothing
+func (l *backgroundLoop) stop() {
+	if l == nil {
+		return
+	}
+	l.cancel()
+	<-l.done
+}
@@ -0,0 +1,74 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"net/http"
+)
+
+const (
+	// ManagerMetricsPort is the port the manager serves metrics on, bound to localhost behind kube-rbac-proxy
+	ManagerMetricsPort            = 8080
+	MaxConcurrentReconcilesMetric = "controller_runtime_max_concurrent_reconciles"
+	ReconcileTotalMetric          = "controller_runtime_reconcile_total"
+)
+
+// ScrapeMetrics GET and parse the Prometheus text format metrics served at url
+func ScrapeMetrics(ctx context.Context, httpClient *http.Client, url string) (map[string]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	parser := expfmt.TextParser{}
+	return parser.TextToMetricFamilies(resp.Body)
+}
+
+// MaxConcurrentReconciles get the max concurrent reconciles of the operator's controllers from the metric families.
+// An error is returned when the metric is missing or the controllers are configured with different values
+func MaxConcurrentReconciles(families map[string]*dto.MetricFamily) (int, error) {
+	family, ok := families[MaxConcurrentReconcilesMetric]
+	if !ok || len(family.GetMetric()) == 0 {
+		return 0, fmt.Errorf("metric %s not found", MaxConcurrentReconcilesMetric)
+	}
+
+	maxConcurrentReconciles := -1
+	for _, metric := range family.GetMetric() {
+		value := int(metric.GetGauge().GetValue())
+		if maxConcurrentReconciles != -1 && value != maxConcurrentReconciles {
+			return 0, fmt.Errorf("controllers have different values for %s: %d and %d",
+				MaxConcurrentReconcilesMetric, maxConcurrentReconciles, value)
+		}
+		maxConcurrentReconciles = value
+	}
+
+	return maxConcurrentReconciles, nil
+}
+
+// ReconcileTotal get the number of reconciles the operator's controllers have run, whatever their result, from the
+// metric families
+func ReconcileTotal(families map[string]*dto.MetricFamily) (float64, error) {
+	family, ok := families[ReconcileTotalMetric]
+	if !ok {
+		return 0, fmt.Errorf("metric %s not found", ReconcileTotalMetric)
+	}
+
+	total := 0.0
+	for _, metric := range family.GetMetric() {
+		total += metric.GetCounter().GetValue()
+	}
+
+	return total, nil
+}
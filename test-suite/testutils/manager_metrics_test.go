@@ -0,0 +1,46 @@
+package testutils
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// parseMetrics parse Prometheus text format metrics as served by the manager
+func parseMetrics(text string) map[string]*dto.MetricFamily {
+	parser := expfmt.TextParser{}
+	families, err := parser.TextToMetricFamilies(strings.NewReader(text))
+	Expect(err).NotTo(HaveOccurred())
+	return families
+}
+
+var _ = Describe("MaxConcurrentReconciles", func() {
+	It("should read the max concurrent reconciles shared by every controller", func() {
+		families := parseMetrics(`# TYPE controller_runtime_max_concurrent_reconciles gauge
+controller_runtime_max_concurrent_reconciles{controller="memcached"} 4
+controller_runtime_max_concurrent_reconciles{controller="memcached-status"} 4
+`)
+		Expect(MaxConcurrentReconciles(families)).To(Equal(4))
+	})
+
+	It("should return an error when the metric is missing", func() {
+		families := parseMetrics(`# TYPE controller_runtime_reconcile_total counter
+controller_runtime_reconcile_total{controller="memcached",result="success"} 12
+`)
+		_, err := MaxConcurrentReconciles(families)
+		Expect(err).To(MatchError("metric controller_runtime_max_concurrent_reconciles not found"))
+	})
+
+	It("should return an error when the controllers have different values", func() {
+		families := parseMetrics(`# TYPE controller_runtime_max_concurrent_reconciles gauge
+controller_runtime_max_concurrent_reconciles{controller="memcached"} 1
+controller_runtime_max_concurrent_reconciles{controller="memcached-status"} 2
+`)
+		_, err := MaxConcurrentReconciles(families)
+		Expect(err).To(MatchError(
+			"controllers have different values for controller_runtime_max_concurrent_reconciles: 1 and 2"))
+	})
+})
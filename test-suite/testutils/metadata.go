@@ -0,0 +1,24 @@
+package testutils
+
+// RunMetadata Configuration a run was executed with, as discovered from the cluster
+type RunMetadata struct {
+	Type                    string `json:"type"`
+	OSDKVersion             string `json:"osdkVersion"`
+	MaxConcurrentReconciles int    `json:"maxConcurrentReconciles"`
+	CPULimit                string `json:"cpuLimit"`
+	MemoryLimit             string `json:"memoryLimit"`
+	MetricsSource           string `json:"metricsSource"`
+	// SampleStats report the effective sample rate of each sampled role so runs with too few points can be rejected
+	SampleStats []SampleStats `json:"sampleStats"`
+	// Valid is false when the operator restarted or its pod was replaced during the run, InvalidReasons says why
+	Valid          bool     `json:"valid"`
+	InvalidReasons []string `json:"invalidReasons,omitempty"`
+	// RunID links the metadata to the streams saved during the same run
+	RunID string `json:"runId"`
+	// Arrivals is how many CRs were created and when each was sent
+	Arrivals ArrivalPattern `json:"arrivals"`
+	// Namespaces is how the CRs were spread over namespaces and which of them the operator watched
+	Namespaces NamespaceSpread `json:"namespaces"`
+	// OperandMode is how the operands of the CRs were run
+	OperandMode string `json:"operandMode"`
+}
@@ -0,0 +1,106 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+	"os"
+)
+
+const (
+	MetricsServerSourceName = "metrics-server"
+	KubeletSourceName       = "kubelet"
+)
+
+// MetricsSource Source of pod and node resource usage metrics
+type MetricsSource interface {
+	// PodMetrics get the metrics of the pods selected by the target
+	PodMetrics(ctx context.Context, target SampleTarget) ([]PodSample, error)
+	// NodeMetrics get the metrics of all nodes in the cluster
+	NodeMetrics(ctx context.Context) ([]NodeSample, error)
+}
+
+// UsageStats Usage statistics which are only available from the kubelet summary API
+type UsageStats struct {
+	// Name of the container the stats are for, empty for pod and node level stats
+	Name                  string  `json:"name,omitempty"`
+	RSSBytes              *uint64 `json:"rssBytes,omitempty"`
+	PageFaults            *uint64 `json:"pageFaults,omitempty"`
+	MajorPageFaults       *uint64 `json:"majorPageFaults,omitempty"`
+	NetworkRxBytes        *uint64 `json:"networkRxBytes,omitempty"`
+	NetworkTxBytes        *uint64 `json:"networkTxBytes,omitempty"`
+	EphemeralStorageBytes *uint64 `json:"ephemeralStorageBytes,omitempty"`
+}
+
+// GetMetricsSourceName get the metrics source to use from the METRICS_SOURCE env, otherwise use metrics-server
+func GetMetricsSourceName() string {
+	if os.Getenv("METRICS_SOURCE") == KubeletSourceName {
+		return KubeletSourceName
+	}
+
+	return MetricsServerSourceName
+}
+
+// NewMetricsSource create the metrics source with the name for the cluster of the rest config
+func NewMetricsSource(name string, restConfig *rest.Config) (MetricsSource, error) {
+	switch name {
+	case MetricsServerSourceName:
+		metricsClient, err := metricsv.NewForConfig(restConfig)
+		if err != nil {
+			return nil, err
+		}
+		return NewMetricsServerSource(metricsClient), nil
+	case KubeletSourceName:
+		httpClient, err := rest.HTTPClientFor(restConfig)
+		if err != nil {
+			return nil, err
+		}
+		return NewKubeletSource(httpClient, restConfig.Host), nil
+	}
+
+	return nil, fmt.Errorf("unknown metrics source %q", name)
+}
+
+// MetricsServerSource Gather metrics from the metrics.k8s.io API served by metrics-server
+type MetricsServerSource struct {
+	metricsClient *metricsv.Clientset
+}
+
+// NewMetricsServerSource create a metrics source using the metrics-server client
+func NewMetricsServerSource(metricsClient *metricsv.Clientset) *MetricsServerSource {
+	return &MetricsServerSource{metricsClient: metricsClient}
+}
+
+// PodMetrics get the metrics of the pods selected by the target from metrics-server
+func (m *MetricsServerSource) PodMetrics(ctx context.Context, target SampleTarget) ([]PodSample, error) {
+	podMetricsList, err := m.metricsClient.MetricsV1beta1().PodMetricses(target.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: target.LabelSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]PodSample, 0, len(podMetricsList.Items))
+	for _, podMetric := range podMetricsList.Items {
+		samples = append(samples, PodSample{PodMetrics: podMetric})
+	}
+
+	return samples, nil
+}
+
+// NodeMetrics get the metrics of all nodes from metrics-server
+func (m *MetricsServerSource) NodeMetrics(ctx context.Context) ([]NodeSample, error) {
+	nodeMetricsList, err := m.metricsClient.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]NodeSample, 0, len(nodeMetricsList.Items))
+	for _, nodeMetric := range nodeMetricsList.Items {
+		samples = append(samples, NodeSample{NodeMetrics: nodeMetric})
+	}
+
+	return samples, nil
+}
@@ -0,0 +1,213 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"math"
+	"os"
+	"path/filepath"
+	kbutil "sigs.k8s.io/kubebuilder/v3/pkg/plugin/util"
+	"sort"
+	"strings"
+)
+
+const (
+	// TenantNamespacePrefix is the prefix of the namespaces generated for the CRs
+	TenantNamespacePrefix = "memcached-tenant-"
+	// DistributionRoundRobin puts the same number of CRs in every namespace, DistributionSkewed puts half as many
+	// CRs in the second namespace as in the first, a third as many in the third and so on
+	DistributionRoundRobin = "round-robin"
+	DistributionSkewed     = "skewed"
+	// WatchScopeSingle, WatchScopeList and WatchScopeAll set WATCH_NAMESPACE of the operator to the only namespace of
+	// the CRs, to every namespace of the CRs separated by commas, or to all namespaces
+	WatchScopeSingle = "single"
+	WatchScopeList   = "list"
+	WatchScopeAll    = "all"
+	// goldenRatioConjugate spreads successive CR indexes evenly over [0, 1) for the skewed distribution
+	goldenRatioConjugate = 0.6180339887498949
+)
+
+// watchNamespaceImports is inserted into the imports of the manager's main.go
+const watchNamespaceImports = `
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+`
+
+// watchNamespaceOptions is inserted into the manager's main.go before the manager is created, limiting its cache
+// to the namespaces in WATCH_NAMESPACE as the ansible and helm operators do
+const watchNamespaceOptions = `
+	watchNamespace := os.Getenv("WATCH_NAMESPACE")
+	var watchNamespaceCache cache.NewCacheFunc
+	if strings.Contains(watchNamespace, ",") {
+		watchNamespaceCache = cache.MultiNamespacedCacheBuilder(strings.Split(watchNamespace, ","))
+		watchNamespace = ""
+	}
+`
+
+// watchNamespaceFields is inserted into the options the manager is created with
+const watchNamespaceFields = `
+		Namespace: watchNamespace,
+		NewCache:  watchNamespaceCache,`
+
+// NamespaceSpread Which namespaces the CRs are created in and which of them the operator watches
+type NamespaceSpread struct {
+	// Count is the number of namespaces generated for the CRs, 0 keeps every CR in the operator's namespace
+	Count        int    `json:"count"`
+	Distribution string `json:"distribution"`
+	// WatchScope is how WATCH_NAMESPACE of the operator was set, empty when it was left as deployed
+	WatchScope string   `json:"watchScope,omitempty"`
+	Namespaces []string `json:"namespaces"`
+	// CRs is the number of CRs first created in each namespace
+	CRs map[string]int `json:"crs"`
+}
+
+// GetNamespaceSpread get how count CRs are spread from the NAMESPACE_COUNT, NAMESPACE_DISTRIBUTION and WATCH_SCOPE
+// env variables. By default every CR is created in the operator's namespace
+func GetNamespaceSpread(count int) (NamespaceSpread, error) {
+	spread := NamespaceSpread{Distribution: DistributionRoundRobin, WatchScope: os.Getenv("WATCH_SCOPE")}
+	if distribution := os.Getenv("NAMESPACE_DISTRIBUTION"); distribution != "" {
+		spread.Distribution = distribution
+	}
+
+	var err error
+	if spread.Count, err = intFromEnv("NAMESPACE_COUNT", 0); err != nil {
+		return spread, err
+	}
+	if spread.Count < 0 {
+		return spread, fmt.Errorf("NAMESPACE_COUNT must not be negative")
+	}
+
+	spread.Namespaces = []string{Namespace}
+	if spread.Count > 0 {
+		spread.Namespaces = make([]string, 0, spread.Count)
+		for i := 0; i < spread.Count; i++ {
+			spread.Namespaces = append(spread.Namespaces, fmt.Sprintf("%s%02d", TenantNamespacePrefix, i))
+		}
+	}
+	if err := spread.Validate(); err != nil {
+		return spread, err
+	}
+
+	spread.CRs = make(map[string]int, len(spread.Namespaces))
+	for i := 0; i < count; i++ {
+		spread.CRs[spread.NamespaceFor(i)]++
+	}
+
+	return spread, nil
+}
+
+// Validate check the distribution and watch scope are known and a single namespace is watched only when there is one
+func (s NamespaceSpread) Validate() error {
+	switch s.Distribution {
+	case DistributionRoundRobin, DistributionSkewed:
+	default:
+		return fmt.Errorf("unknown NAMESPACE_DISTRIBUTION %q", s.Distribution)
+	}
+
+	switch s.WatchScope {
+	case "", WatchScopeList, WatchScopeAll:
+	case WatchScopeSingle:
+		if len(s.Namespaces) != 1 {
+			return fmt.Errorf("WATCH_SCOPE %s needs the CRs in a single namespace, NAMESPACE_COUNT is %d", s.WatchScope, s.Count)
+		}
+	default:
+		return fmt.Errorf("unknown WATCH_SCOPE %q", s.WatchScope)
+	}
+
+	return nil
+}
+
+// Generated check whether the CRs are created in generated namespaces rather than the operator's namespace
+func (s NamespaceSpread) Generated() bool {
+	return s.Count > 0
+}
+
+// NamespaceFor get the namespace the CR with the index is created in
+func (s NamespaceSpread) NamespaceFor(i int) string {
+	if s.Distribution != DistributionSkewed {
+		return s.Namespaces[i%len(s.Namespaces)]
+	}
+
+	// Namespace k gets a share of the CRs proportional to 1/(k+1)
+	total := 0.0
+	for k := range s.Namespaces {
+		total += 1 / float64(k+1)
+	}
+	u := math.Mod(float64(i)*goldenRatioConjugate, 1) * total
+	for k, namespace := range s.Namespaces {
+		u -= 1 / float64(k+1)
+		if u < 0 {
+			return namespace
+		}
+	}
+
+	return s.Namespaces[len(s.Namespaces)-1]
+}
+
+// ListNamespace get the namespace to list and watch the CRs and their operands in, all namespaces when the CRs are
+// spread over more than one
+func (s NamespaceSpread) ListNamespace() string {
+	if len(s.Namespaces) == 1 {
+		return s.Namespaces[0]
+	}
+
+	return metav1.NamespaceAll
+}
+
+// WatchNamespace get the value WATCH_NAMESPACE of the operator is set to, false if it is left as deployed
+func (s NamespaceSpread) WatchNamespace() (string, bool) {
+	switch s.WatchScope {
+	case WatchScopeSingle:
+		return s.Namespaces[0], true
+	case WatchScopeList:
+		namespaces := append([]string{}, s.Namespaces...)
+		sort.Strings(namespaces)
+		return strings.Join(namespaces, ","), true
+	case WatchScopeAll:
+		return metav1.NamespaceAll, true
+	}
+
+	return "", false
+}
+
+// CreateNamespaces create the namespaces, leaving those which already exist
+func CreateNamespaces(ctx context.Context, clientset kubernetes.Interface, names []string) error {
+	for _, name := range names {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if _, err := clientset.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteNamespaces delete the namespaces, ignoring those which are already gone
+func DeleteNamespaces(ctx context.Context, clientset kubernetes.Interface, names []string) error {
+	for _, name := range names {
+		if err := clientset.CoreV1().Namespaces().Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EnableWatchNamespace Patch the main.go of a go/v3 project in projectDir to limit the manager's cache to the
+// namespaces in WATCH_NAMESPACE, all namespaces when it is not set, must be called before the image is built
+func EnableWatchNamespace(projectDir string) error {
+	mainFile := filepath.Join(projectDir, "main.go")
+	if err := kbutil.InsertCode(mainFile, `"flag"`, watchNamespaceImports); err != nil {
+		return err
+	}
+	if err := kbutil.InsertCode(mainFile, "ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))", watchNamespaceOptions); err != nil {
+		return err
+	}
+
+	return kbutil.InsertCode(mainFile, "ctrl.Options{", watchNamespaceFields)
+}
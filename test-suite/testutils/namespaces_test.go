@@ -0,0 +1,125 @@
+package testutils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("NamespaceSpread", func() {
+	AfterEach(func() {
+		for _, name := range []string{"NAMESPACE_COUNT", "NAMESPACE_DISTRIBUTION", "WATCH_SCOPE"} {
+			Expect(os.Unsetenv(name)).To(Succeed())
+		}
+	})
+
+	It("should keep every CR in the operator's namespace by default", func() {
+		spread, err := GetNamespaceSpread(15)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spread.Generated()).To(BeFalse())
+		Expect(spread.Namespaces).To(Equal([]string{Namespace}))
+		Expect(spread.CRs).To(Equal(map[string]int{Namespace: 15}))
+		Expect(spread.ListNamespace()).To(Equal(Namespace))
+		_, set := spread.WatchNamespace()
+		Expect(set).To(BeFalse())
+	})
+
+	It("should spread CRs over the generated namespaces in turn", func() {
+		Expect(os.Setenv("NAMESPACE_COUNT", "3")).To(Succeed())
+		Expect(os.Setenv("WATCH_SCOPE", WatchScopeList)).To(Succeed())
+		spread, err := GetNamespaceSpread(7)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spread.Generated()).To(BeTrue())
+		Expect(spread.Namespaces).To(Equal([]string{"memcached-tenant-00", "memcached-tenant-01", "memcached-tenant-02"}))
+		Expect(spread.CRs).To(Equal(map[string]int{"memcached-tenant-00": 3, "memcached-tenant-01": 2, "memcached-tenant-02": 2}))
+		Expect(spread.NamespaceFor(4)).To(Equal("memcached-tenant-01"))
+		Expect(spread.ListNamespace()).To(Equal(metav1.NamespaceAll))
+		watchNamespace, set := spread.WatchNamespace()
+		Expect(set).To(BeTrue())
+		Expect(watchNamespace).To(Equal("memcached-tenant-00,memcached-tenant-01,memcached-tenant-02"))
+	})
+
+	It("should put fewer CRs in each later namespace when skewed", func() {
+		Expect(os.Setenv("NAMESPACE_COUNT", "4")).To(Succeed())
+		Expect(os.Setenv("NAMESPACE_DISTRIBUTION", DistributionSkewed)).To(Succeed())
+		spread, err := GetNamespaceSpread(1000)
+		Expect(err).NotTo(HaveOccurred())
+		// The shares are 1, 1/2, 1/3 and 1/4 of 1000/(1+1/2+1/3+1/4)
+		Expect(spread.CRs["memcached-tenant-00"]).To(BeNumerically("~", 480, 5))
+		Expect(spread.CRs["memcached-tenant-01"]).To(BeNumerically("~", 240, 5))
+		Expect(spread.CRs["memcached-tenant-02"]).To(BeNumerically("~", 160, 5))
+		Expect(spread.CRs["memcached-tenant-03"]).To(BeNumerically("~", 120, 5))
+		Expect(spread.NamespaceFor(0)).To(Equal("memcached-tenant-00"))
+	})
+
+	It("should only watch a single namespace when the CRs are in one", func() {
+		Expect(os.Setenv("WATCH_SCOPE", WatchScopeSingle)).To(Succeed())
+		spread, err := GetNamespaceSpread(15)
+		Expect(err).NotTo(HaveOccurred())
+		watchNamespace, set := spread.WatchNamespace()
+		Expect(set).To(BeTrue())
+		Expect(watchNamespace).To(Equal(Namespace))
+
+		Expect(os.Setenv("NAMESPACE_COUNT", "2")).To(Succeed())
+		_, err = GetNamespaceSpread(15)
+		Expect(err).To(MatchError("WATCH_SCOPE single needs the CRs in a single namespace, NAMESPACE_COUNT is 2"))
+
+		Expect(os.Setenv("WATCH_SCOPE", WatchScopeAll)).To(Succeed())
+		spread, err = GetNamespaceSpread(15)
+		Expect(err).NotTo(HaveOccurred())
+		watchNamespace, set = spread.WatchNamespace()
+		Expect(set).To(BeTrue())
+		Expect(watchNamespace).To(BeEmpty())
+	})
+
+	It("should create and delete the namespaces", func() {
+		ctx := context.TODO()
+		clientset := fake.NewSimpleClientset()
+		names := []string{"memcached-tenant-00", "memcached-tenant-01"}
+		Expect(CreateNamespaces(ctx, clientset, names)).To(Succeed())
+		Expect(CreateNamespaces(ctx, clientset, names)).To(Succeed())
+		namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(namespaces.Items).To(HaveLen(2))
+
+		Expect(DeleteNamespaces(ctx, clientset, names)).To(Succeed())
+		Expect(DeleteNamespaces(ctx, clientset, names)).To(Succeed())
+		namespaces, err = clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(namespaces.Items).To(BeEmpty())
+	})
+
+	It("should patch the manager to watch the namespaces in WATCH_NAMESPACE", func() {
+		dir, err := os.MkdirTemp("", "project")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		mainFile := filepath.Join(dir, "main.go")
+		Expect(os.WriteFile(mainFile, []byte(`package main
+
+import (
+	"flag"
+	"os"
+)
+
+func main() {
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme: scheme,
+	})
+}
+`), 0644)).To(Succeed())
+
+		Expect(EnableWatchNamespace(dir)).To(Succeed())
+		patched, err := os.ReadFile(mainFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(patched)).To(ContainSubstring(`"sigs.k8s.io/controller-runtime/pkg/cache"`))
+		Expect(string(patched)).To(ContainSubstring(`watchNamespace := os.Getenv("WATCH_NAMESPACE")`))
+		Expect(string(patched)).To(ContainSubstring("ctrl.Options{\n\t\tNamespace: watchNamespace,\n\t\tNewCache:  watchNamespaceCache,\n\t\tScheme: scheme,"))
+	})
+})
@@ -0,0 +1,104 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	kbutil "sigs.k8s.io/kubebuilder/v3/pkg/plugin/util"
+	"time"
+)
+
+const (
+	// ProfileDuration is how long the CPU profile of the manager is recorded for
+	ProfileDuration = 30 * time.Second
+	PprofDir        = "pprof"
+)
+
+// pprofImports is inserted into the imports of the manager's main.go
+const pprofImports = `
+	"net/http"
+	"net/http/pprof"
+`
+
+// pprofHandlers is inserted into the manager's main.go once the controllers are set up, serving the profiles
+// alongside the metrics
+const pprofHandlers = `
+	for path, handler := range map[string]http.Handler{
+		"/debug/pprof/":        http.HandlerFunc(pprof.Index),
+		"/debug/pprof/profile": http.HandlerFunc(pprof.Profile),
+	} {
+		if err := mgr.AddMetricsExtraHandler(path, handler); err != nil {
+			setupLog.Error(err, "unable to set up pprof handler", "path", path)
+			os.Exit(1)
+		}
+	}
+`
+
+// EnablePprof Patch the main.go of a go/v3 project in projectDir to serve the pprof endpoints from the manager's
+// metrics server, must be called before the image is built
+func EnablePprof(projectDir string) error {
+	mainFile := filepath.Join(projectDir, "main.go")
+	if err := kbutil.InsertCode(mainFile, `"flag"`, pprofImports); err != nil {
+		return err
+	}
+
+	return kbutil.InsertCode(mainFile, "//+kubebuilder:scaffold:builder", pprofHandlers)
+}
+
+// SaveProfiles Record a CPU profile over ProfileDuration followed by a heap profile from the pprof endpoints
+// served at baseURL. They are saved as <runID>-<label>-cpu.pb.gz and <runID>-<label>-heap.pb.gz in pprof in the
+// results directory dir
+func SaveProfiles(ctx context.Context, httpClient *http.Client, baseURL, dir, runID, label string) error {
+	path, err := MakeResultsDir(fmt.Sprintf("%s/%s", dir, PprofDir))
+	if err != nil {
+		return err
+	}
+
+	profiles := []struct {
+		name string
+		url  string
+	}{
+		{"cpu", fmt.Sprintf("%s/debug/pprof/profile?seconds=%d", baseURL, int(ProfileDuration.Seconds()))},
+		{"heap", fmt.Sprintf("%s/debug/pprof/heap", baseURL)},
+	}
+	for _, profile := range profiles {
+		file := fmt.Sprintf("%s/%s-%s-%s.pb.gz", path, runID, label, profile.name)
+		if err := saveProfile(ctx, httpClient, profile.url, file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveProfile GET the profile served at url, which is gzipped protobuf, and write it to file as is
+func saveProfile(ctx context.Context, httpClient *http.Client, url, file string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,73 @@
+package testutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const fakeMainGo = `package main
+
+import (
+	"flag"
+	"os"
+)
+
+func main() {
+	//+kubebuilder:scaffold:builder
+}
+`
+
+var _ = Describe("pprof", func() {
+	var resultsDir string
+
+	BeforeEach(func() {
+		var err error
+		resultsDir, err = os.MkdirTemp("", "results")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Setenv("RESULTS_DIR", resultsDir)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.Unsetenv("RESULTS_DIR")).To(Succeed())
+		Expect(os.RemoveAll(resultsDir)).To(Succeed())
+	})
+
+	It("should register the pprof handlers in main.go", func() {
+		Expect(os.WriteFile(filepath.Join(resultsDir, "main.go"), []byte(fakeMainGo), 0644)).To(Succeed())
+		Expect(EnablePprof(resultsDir)).To(Succeed())
+
+		mainGo, err := os.ReadFile(filepath.Join(resultsDir, "main.go"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(mainGo)).To(ContainSubstring(`"net/http/pprof"`))
+		Expect(string(mainGo)).To(ContainSubstring(`mgr.AddMetricsExtraHandler(path, handler)`))
+	})
+
+	It("should save the cpu and heap profiles", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/debug/pprof/profile":
+				Expect(r.URL.Query().Get("seconds")).To(Equal("30"))
+				_, _ = w.Write([]byte("cpu"))
+			case "/debug/pprof/heap":
+				_, _ = w.Write([]byte("heap"))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		Expect(SaveProfiles(context.TODO(), server.Client(), server.URL, "pprof-test", "123", "baseline")).To(Succeed())
+
+		for profile, content := range map[string]string{"cpu": "cpu", "heap": "heap"} {
+			data, err := os.ReadFile(filepath.Join(resultsDir, "pprof-test", PprofDir, "123-baseline-"+profile+".pb.gz"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal(content))
+		}
+	})
+})
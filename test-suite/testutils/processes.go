@@ -0,0 +1,275 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ProcessOperator        = "operator"
+	ProcessAnsibleRunner   = "ansible-runner"
+	ProcessAnsiblePlaybook = "ansible-playbook"
+	ProcessOther           = "other"
+	// processInterval is how often /proc is read, each read is a kubectl exec so it is kept well above a second
+	processInterval = 5 * time.Second
+)
+
+// processScript prints the pid of the shell running it, the clock ticks per second, the page size and then the stat
+// line of every process in the container
+const processScript = `echo $$; getconf CLK_TCK; getconf PAGESIZE; cat /proc/[0-9]*/stat 2>/dev/null || true`
+
+// PodExecFunc Run a command in the container being sampled and get its stdout
+type PodExecFunc func(ctx context.Context, command ...string) (string, error)
+
+// ProcessUsage The cpu and memory used by the processes of a category alive when /proc was read
+type ProcessUsage struct {
+	Category  string `json:"category"`
+	Processes int    `json:"processes"`
+	// CPUCores is the cpu used since the previous read, processes which started and exited in between are missed
+	CPUCores float64 `json:"cpuCores"`
+	RSSBytes uint64  `json:"rssBytes"`
+}
+
+// ProcessSample The usage of each process category in a container tagged with the phase of the run it was read in
+type ProcessSample struct {
+	Phase Phase `json:"phase"`
+	// Offset is the number of milliseconds since the start of the run
+	Offset     int64          `json:"offset"`
+	ObservedAt time.Time      `json:"observedAt"`
+	Usage      []ProcessUsage `json:"usage"`
+}
+
+// procStat The fields of a /proc/<pid>/stat line the collector uses
+type procStat struct {
+	pid       int
+	comm      string
+	ppid      int
+	cpuTicks  uint64
+	startTime uint64
+	rssPages  uint64
+}
+
+// ProcessCollector Periodically read /proc inside a container to attribute its cpu and memory to the operator binary,
+// ansible-runner and ansible-playbook processes
+type ProcessCollector struct {
+	exec     PodExecFunc
+	timeline *Timeline
+
+	mu       sync.Mutex
+	writer   *StreamWriter
+	writeErr error
+	// lastTicks is the cpu ticks of each process at the last read, keyed by pid and start time so reused pids are told apart
+	lastTicks map[string]uint64
+	lastRead  time.Time
+
+	loop *backgroundLoop
+}
+
+// NewProcessCollector create a collector reading /proc with exec
+func NewProcessCollector(exec PodExecFunc, timeline *Timeline) *ProcessCollector {
+	return &ProcessCollector{
+		exec:      exec,
+		timeline:  timeline,
+		lastTicks: map[string]uint64{},
+	}
+}
+
+// ProcessCategory get the category of a process from the command name in its stat line, which the kernel truncates
+// to 15 characters. Python workers forked by ansible-playbook keep its name
+func ProcessCategory(comm string) string {
+	switch {
+	case strings.HasPrefix(comm, "ansible-operato"):
+		return ProcessOperator
+	case strings.HasPrefix(comm, "ansible-runner"):
+		return ProcessAnsibleRunner
+	case strings.HasPrefix(comm, "ansible-playboo"):
+		return ProcessAnsiblePlaybook
+	default:
+		return ProcessOther
+	}
+}
+
+// Start reading /proc in the background, streaming the samples to processes in the results directory dir
+func (p *ProcessCollector) Start(ctx context.Context, dir string) error {
+	writer, err := NewStreamWriter(fmt.Sprintf("%s/processes", dir))
+	if err != nil {
+		return err
+	}
+	p.writer = writer
+	p.loop = startLoop(ctx, processInterval, p.collect)
+
+	return nil
+}
+
+// Stop reading /proc and mark the stream complete
+func (p *ProcessCollector) Stop() error {
+	return p.finish(true)
+}
+
+// Close stop reading /proc if Stop was not called and mark the stream incomplete
+func (p *ProcessCollector) Close() error {
+	return p.finish(false)
+}
+
+// collect read /proc once and stream the usage of each category
+func (p *ProcessCollector) collect(ctx context.Context) {
+	out, err := p.exec(ctx, "sh", "-c", processScript)
+	if err != nil {
+		return
+	}
+	readAt := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sample, err := p.parse(out, readAt)
+	if err != nil {
+		return
+	}
+	if p.writer != nil {
+		if err := p.writer.Write(sample); err != nil && p.writeErr == nil {
+			p.writeErr = err
+		}
+	}
+}
+
+// parse turn the output of processScript into a sample, must be called with the lock held
+func (p *ProcessCollector) parse(out string, readAt time.Time) (*ProcessSample, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 3 {
+		return nil, fmt.Errorf("unexpected output reading /proc: %q", out)
+	}
+	shellPid, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return nil, err
+	}
+	clockTicks, err := strconv.ParseFloat(strings.TrimSpace(lines[1]), 64)
+	if err != nil {
+		return nil, err
+	}
+	pageSize, err := strconv.ParseUint(strings.TrimSpace(lines[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	elapsed := 0.0
+	if !p.lastRead.IsZero() {
+		elapsed = readAt.Sub(p.lastRead).Seconds()
+	}
+	p.lastRead = readAt
+
+	usage := map[string]*ProcessUsage{}
+	for _, category := range []string{ProcessOperator, ProcessAnsibleRunner, ProcessAnsiblePlaybook, ProcessOther} {
+		usage[category] = &ProcessUsage{Category: category}
+	}
+
+	ticks := map[string]uint64{}
+	for _, line := range lines[3:] {
+		stat, err := parseProcStat(line)
+		if err != nil {
+			continue
+		}
+		// Skip the shell reading /proc and the commands it ran
+		if stat.pid == shellPid || stat.ppid == shellPid {
+			continue
+		}
+
+		categoryUsage := usage[ProcessCategory(stat.comm)]
+		categoryUsage.Processes++
+		categoryUsage.RSSBytes += stat.rssPages * pageSize
+
+		key := fmt.Sprintf("%d/%d", stat.pid, stat.startTime)
+		ticks[key] = stat.cpuTicks
+		if elapsed > 0 {
+			// A process started since the last read used all of its cpu since then
+			categoryUsage.CPUCores += float64(stat.cpuTicks-p.lastTicks[key]) / clockTicks / elapsed
+		}
+	}
+	p.lastTicks = ticks
+
+	phase, offset := p.timeline.Current()
+	sample := &ProcessSample{
+		Phase:      phase,
+		Offset:     offset.Milliseconds(),
+		ObservedAt: readAt,
+	}
+	for _, categoryUsage := range usage {
+		sample.Usage = append(sample.Usage, *categoryUsage)
+	}
+	sort.Slice(sample.Usage, func(i, j int) bool {
+		return sample.Usage[i].Category < sample.Usage[j].Category
+	})
+
+	return sample, nil
+}
+
+// parseProcStat parse a /proc/<pid>/stat line. The command name is in parentheses and may itself contain spaces
+// or parentheses, so the fields after it are found from the last closing parenthesis
+func parseProcStat(line string) (*procStat, error) {
+	open := strings.Index(line, "(")
+	closing := strings.LastIndex(line, ")")
+	if open < 0 || closing < open {
+		return nil, fmt.Errorf("malformed stat line: %q", line)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(line[:open]))
+	if err != nil {
+		return nil, err
+	}
+	// fields starts at the state, the third field of the line
+	fields := strings.Fields(line[closing+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("malformed stat line: %q", line)
+	}
+
+	// field parses a numeric field of the line as numbered in proc(5)
+	field := func(n int) (uint64, error) {
+		return strconv.ParseUint(fields[n-3], 10, 64)
+	}
+	stat := &procStat{pid: pid, comm: line[open+1 : closing]}
+	ppid, err := field(4)
+	if err != nil {
+		return nil, err
+	}
+	stat.ppid = int(ppid)
+	utime, err := field(14)
+	if err != nil {
+		return nil, err
+	}
+	stime, err := field(15)
+	if err != nil {
+		return nil, err
+	}
+	stat.cpuTicks = utime + stime
+	if stat.startTime, err = field(22); err != nil {
+		return nil, err
+	}
+	if stat.rssPages, err = field(24); err != nil {
+		return nil, err
+	}
+
+	return stat, nil
+}
+
+// finish stop reading /proc and write the index of the stream
+func (p *ProcessCollector) finish(complete bool) error {
+	p.loop.stop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.writer == nil {
+		return nil
+	}
+	err := p.writeErr
+	if finishErr := p.writer.Finish(complete, p.timeline); err == nil {
+		err = finishErr
+	}
+
+	return err
+}
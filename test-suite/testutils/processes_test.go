@@ -0,0 +1,63 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeProcStat create a /proc/<pid>/stat line with the fields the collector reads set
+func fakeProcStat(pid int, comm string, ppid int, utime, stime, startTime, rssPages uint64) string {
+	return fmt.Sprintf("%d (%s) S %d 1 1 0 -1 4194560 100 0 0 0 %d %d 0 0 20 0 4 0 %d 123456789 %d "+
+		"18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0", pid, comm, ppid, utime, stime, startTime, rssPages)
+}
+
+var _ = Describe("ProcessCollector", func() {
+	It("should attribute cpu and memory to each process category", func() {
+		collector := NewProcessCollector(nil, NewTimeline())
+		start := time.Now()
+
+		first := fmt.Sprintf("500\n100\n4096\n%s\n%s\n%s\n",
+			fakeProcStat(1, "ansible-operato", 0, 100, 50, 10, 1000),
+			fakeProcStat(20, "ansible-runner", 1, 10, 0, 200, 500),
+			fakeProcStat(500, "sh", 0, 0, 0, 900, 10))
+		sample, err := collector.parse(first, start)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sample.Usage).To(ContainElement(ProcessUsage{Category: ProcessOperator, Processes: 1, RSSBytes: 1000 * 4096}))
+		Expect(sample.Usage).To(ContainElement(ProcessUsage{Category: ProcessOther}))
+
+		second := fmt.Sprintf("600\n100\n4096\n%s\n%s\n%s\n%s\n%s\n",
+			fakeProcStat(1, "ansible-operato", 0, 300, 50, 10, 1200),
+			fakeProcStat(20, "ansible-runner", 1, 60, 0, 200, 500),
+			fakeProcStat(21, "ansible-playboo", 20, 100, 100, 300, 2000),
+			fakeProcStat(22, "ansible-playboo", 21, 50, 50, 301, 1000),
+			fakeProcStat(601, "cat", 600, 0, 0, 1000, 10))
+		sample, err = collector.parse(second, start.Add(2*time.Second))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sample.Usage).To(Equal([]ProcessUsage{
+			{Category: ProcessAnsiblePlaybook, Processes: 2, CPUCores: 1.5, RSSBytes: 3000 * 4096},
+			{Category: ProcessAnsibleRunner, Processes: 1, CPUCores: 0.25, RSSBytes: 500 * 4096},
+			{Category: ProcessOperator, Processes: 1, CPUCores: 1, RSSBytes: 1200 * 4096},
+			{Category: ProcessOther},
+		}))
+	})
+
+	It("should parse command names containing spaces and parentheses", func() {
+		stat, err := parseProcStat(fakeProcStat(42, "a (b) c", 1, 3, 4, 5, 6))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*stat).To(Equal(procStat{pid: 42, comm: "a (b) c", ppid: 1, cpuTicks: 7, startTime: 5, rssPages: 6}))
+	})
+
+	It("should read /proc in the container through exec", func() {
+		exec := func(_ context.Context, command ...string) (string, error) {
+			Expect(command).To(Equal([]string{"sh", "-c", processScript}))
+			return fmt.Sprintf("500\n100\n4096\n%s\n", fakeProcStat(1, "ansible-operato", 0, 100, 50, 10, 1000)), nil
+		}
+		collector := NewProcessCollector(exec, NewTimeline())
+		collector.collect(context.TODO())
+		Expect(collector.lastTicks).To(HaveKeyWithValue("1/10", uint64(150)))
+	})
+})
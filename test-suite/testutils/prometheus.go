@@ -0,0 +1,122 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PrometheusNamespace = "default"
+	PrometheusService   = "prometheus-operated:web"
+	// prometheusMaxPoints is the maximum number of points Prometheus returns for a single range query series
+	prometheusMaxPoints = 11000
+)
+
+// PrometheusSeries A single series of a range query result.
+// Each value is a pair of the unix timestamp in seconds and the sample value as a string
+type PrometheusSeries struct {
+	Metric map[string]string `json:"metric"`
+	Values [][]interface{}   `json:"values"`
+}
+
+type prometheusResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Data      struct {
+		ResultType string             `json:"resultType"`
+		Result     []PrometheusSeries `json:"result"`
+	} `json:"data"`
+}
+
+// PrometheusClient Query the Prometheus HTTP API
+type PrometheusClient struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewPrometheusClient create a client for the Prometheus HTTP API served at baseURL
+func NewPrometheusClient(httpClient *http.Client, baseURL string) *PrometheusClient {
+	return &PrometheusClient{
+		httpClient: httpClient,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// NewPrometheusClientForConfig create a client for the Prometheus instance installed by InstallPrerequisites,
+// reached through the API server service proxy
+func NewPrometheusClientForConfig(restConfig *rest.Config) (*PrometheusClient, error) {
+	httpClient, err := rest.HTTPClientFor(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPrometheusClient(httpClient, fmt.Sprintf("%s/api/v1/namespaces/%s/services/%s/proxy",
+		strings.TrimSuffix(restConfig.Host, "/"), PrometheusNamespace, PrometheusService)), nil
+}
+
+// QueryRange evaluate the query over the time range at the resolution of step
+func (p *PrometheusClient) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]PrometheusSeries, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", strconv.FormatFloat(float64(start.UnixMilli())/1000, 'f', 3, 64))
+	params.Set("end", strconv.FormatFloat(float64(end.UnixMilli())/1000, 'f', 3, 64))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	resp := prometheusResponse{}
+	if err := getJSON(ctx, p.httpClient, fmt.Sprintf("%s/api/v1/query_range?%s", p.baseURL, params.Encode()), &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "success" {
+		return nil, fmt.Errorf("query %q failed: %s: %s", query, resp.ErrorType, resp.Error)
+	}
+
+	return resp.Data.Result, nil
+}
+
+// OperatorPrometheusQueries get the queries for the controller-runtime and Go runtime metrics of the operator,
+// keyed by the name of the directory their results are saved to
+func OperatorPrometheusQueries(namespace string) map[string]string {
+	return map[string]string{
+		"reconcileTime":          fmt.Sprintf(`{__name__=~"controller_runtime_reconcile_time_seconds_(bucket|sum|count)",namespace=%q}`, namespace),
+		"reconcileTotal":         fmt.Sprintf(`controller_runtime_reconcile_total{namespace=%q}`, namespace),
+		"workqueueDepth":         fmt.Sprintf(`workqueue_depth{namespace=%q}`, namespace),
+		"workqueueQueueDuration": fmt.Sprintf(`{__name__=~"workqueue_queue_duration_seconds_(bucket|sum|count)",namespace=%q}`, namespace),
+		"restClientRequests":     fmt.Sprintf(`rest_client_requests_total{namespace=%q}`, namespace),
+		"goRuntime":              fmt.Sprintf(`{__name__=~"go_.+|process_.+",namespace=%q}`, namespace),
+	}
+}
+
+// RangeQueryStep get the smallest step of at least minStep which keeps each series of a range query over
+// duration within the number of points Prometheus allows
+func RangeQueryStep(duration, minStep time.Duration) time.Duration {
+	step := duration / prometheusMaxPoints
+	if step < minStep {
+		return minStep
+	}
+
+	return step.Round(time.Second) + time.Second
+}
+
+// SavePrometheusQueries run the range queries over the time range and save each result under dir/prometheus
+func SavePrometheusQueries(ctx context.Context, client *PrometheusClient, dir string, queries map[string]string, start, end time.Time) error {
+	step := RangeQueryStep(end.Sub(start), time.Second)
+	for name, query := range queries {
+		series, err := client.QueryRange(ctx, query, start, end, step)
+		if err != nil {
+			return err
+		}
+
+		if err := SaveAsJsonToDir(fmt.Sprintf("%s/prometheus/%s", dir, name), series); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package testutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const fakeRangeResult = `{"status":"success","data":{"resultType":"matrix","result":[
+{"metric":{"__name__":"workqueue_depth","name":"memcached"},"values":[[1659348000,"0"],[1659348001,"3"]]}]}}`
+
+var _ = Describe("PrometheusClient", func() {
+	var (
+		server  *httptest.Server
+		client  *PrometheusClient
+		queries []string
+		// params are checked in the specs, as a failed assertion in the handler goroutine would not fail the spec
+		params []url.Values
+	)
+
+	BeforeEach(func() {
+		queries = nil
+		params = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v1/query_range" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			query := r.URL.Query().Get("query")
+			queries = append(queries, query)
+			params = append(params, r.URL.Query())
+			if query == "bad(" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+				return
+			}
+			_, _ = w.Write([]byte(fakeRangeResult))
+		}))
+		client = NewPrometheusClient(server.Client(), server.URL)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should return the series of a range query", func() {
+		start := time.Unix(1659348000, 0)
+		series, err := client.QueryRange(context.TODO(), "workqueue_depth", start, start.Add(time.Minute), time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(series).To(HaveLen(1))
+		Expect(series[0].Metric).To(HaveKeyWithValue("name", "memcached"))
+		Expect(series[0].Values).To(HaveLen(2))
+		Expect(series[0].Values[1][1]).To(Equal("3"))
+
+		Expect(params).To(HaveLen(1))
+		Expect(params[0].Get("start")).To(Equal("1659348000.000"))
+		Expect(params[0].Get("end")).To(Equal("1659348060.000"))
+		Expect(params[0].Get("step")).To(Equal("1"))
+	})
+
+	It("should return an error for a failed query", func() {
+		start := time.Unix(1659348000, 0)
+		_, err := client.QueryRange(context.TODO(), "bad(", start, start.Add(time.Minute), time.Second)
+		Expect(err).To(MatchError(ContainSubstring("parse error")))
+	})
+
+	It("should save the result of each query under the prometheus directory", func() {
+		resultsDir, err := os.MkdirTemp("", "results")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(resultsDir)
+		Expect(os.Setenv("RESULTS_DIR", resultsDir)).To(Succeed())
+		defer os.Unsetenv("RESULTS_DIR")
+
+		start := time.Unix(1659348000, 0)
+		Expect(SavePrometheusQueries(context.TODO(), client, "go-1-128Mi-500m",
+			OperatorPrometheusQueries(Namespace), start, start.Add(time.Minute))).To(Succeed())
+		Expect(queries).To(HaveLen(len(OperatorPrometheusQueries(Namespace))))
+		Expect(queries).To(ContainElement(`workqueue_depth{namespace="memcached-operator-system"}`))
+
+		files, err := filepath.Glob(filepath.Join(resultsDir, "go-1-128Mi-500m", "prometheus", "workqueueDepth", "*.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("RangeQueryStep", func() {
+	It("should use the minimum step for short runs", func() {
+		Expect(RangeQueryStep(10*time.Minute, time.Second)).To(Equal(time.Second))
+	})
+
+	It("should keep long runs within the point limit", func() {
+		duration := 8 * time.Hour
+		step := RangeQueryStep(duration, time.Second)
+		Expect(int64(duration / step)).To(BeNumerically("<=", prometheusMaxPoints))
+	})
+})
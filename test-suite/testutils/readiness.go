@@ -0,0 +1,124 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// AnsibleSuccessfulCondition is set by the ansible operator once a reconcile of the CR ran without failed tasks
+	AnsibleSuccessfulCondition = "Successful"
+	// HelmDeployedCondition is set by the helm operator once the release of the CR is installed or upgraded
+	HelmDeployedCondition = "Deployed"
+)
+
+// ReadinessPredicate Decide from the status of a CR whether the operator reports it is done
+type ReadinessPredicate func(cr *unstructured.Unstructured) bool
+
+// GetReadinessPredicate get the predicate for the CRs of the operator type
+func GetReadinessPredicate(oType string) ReadinessPredicate {
+	switch oType {
+	case AnsibleType:
+		return ConditionTrue(AnsibleSuccessfulCondition)
+	case HelmType:
+		return ConditionTrue(HelmDeployedCondition)
+	default:
+		return StatusNodesReady
+	}
+}
+
+// StatusNodesReady check whether the go sample lists as many pods in status.nodes as spec.size wants
+func StatusNodesReady(cr *unstructured.Unstructured) bool {
+	nodes, _, err := unstructured.NestedStringSlice(cr.Object, "status", "nodes")
+	if err != nil {
+		return false
+	}
+	size, found, err := unstructured.NestedInt64(cr.Object, "spec", "size")
+	if err != nil {
+		return false
+	}
+	if !found {
+		size = 1
+	}
+
+	return len(nodes) > 0 && int64(len(nodes)) >= size
+}
+
+// ConditionTrue create a predicate checking whether the CR has a status condition of conditionType which is True
+func ConditionTrue(conditionType string) ReadinessPredicate {
+	return func(cr *unstructured.Unstructured) bool {
+		conditions, _, err := unstructured.NestedSlice(cr.Object, "status", "conditions")
+		if err != nil {
+			return false
+		}
+		for _, condition := range conditions {
+			fields, ok := condition.(map[string]interface{})
+			if ok && fields["type"] == conditionType && fields["status"] == string(metav1.ConditionTrue) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// CRsReady check whether there are count CRs of gvr in namespace and the operator reports all of them ready
+func CRsReady(ctx context.Context, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, namespace string,
+	count int, ready ReadinessPredicate) error {
+	crs, err := dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	readyCRs := 0
+	for i := range crs.Items {
+		if ready(&crs.Items[i]) {
+			readyCRs++
+		}
+	}
+	if readyCRs < count {
+		return fmt.Errorf("%d of %d CRs are ready", readyCRs, count)
+	}
+
+	return nil
+}
+
+// PodsRunning check whether there are count pods matching the label selector in namespace and all of them are running
+func PodsRunning(ctx context.Context, clientset kubernetes.Interface, namespace, labelSelector string, count int) error {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return err
+	}
+
+	running := 0
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			return fmt.Errorf("pod %s is %s", pod.Name, pod.Status.Phase)
+		}
+		running++
+	}
+	if running != count {
+		return fmt.Errorf("%d of %d pods are running", running, count)
+	}
+
+	return nil
+}
+
+// PodsGone check whether every pod matching the label selector in namespace is gone
+func PodsGone(ctx context.Context, clientset kubernetes.Interface, namespace, labelSelector string) error {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) > 0 {
+		return fmt.Errorf("waiting for %d pods to be terminated", len(pods.Items))
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package testutils
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// withStatus create a memcached CR with the spec and status
+func withStatus(name string, spec, status map[string]interface{}) *unstructured.Unstructured {
+	cr := fakeMemcached(name, "")
+	cr.Object["spec"] = spec
+	cr.Object["status"] = status
+
+	return cr
+}
+
+var _ = Describe("Readiness", func() {
+	It("should wait for the go sample to list spec.size pods in status.nodes", func() {
+		ready := GetReadinessPredicate(GoType)
+
+		Expect(ready(withStatus("a", map[string]interface{}{"size": int64(2)},
+			map[string]interface{}{"nodes": []interface{}{"a-1"}}))).To(BeFalse())
+		Expect(ready(withStatus("a", map[string]interface{}{"size": int64(2)},
+			map[string]interface{}{"nodes": []interface{}{"a-1", "a-2"}}))).To(BeTrue())
+		Expect(ready(withStatus("a", map[string]interface{}{},
+			map[string]interface{}{"nodes": []interface{}{"a-1"}}))).To(BeTrue())
+		Expect(ready(withStatus("a", map[string]interface{}{}, map[string]interface{}{}))).To(BeFalse())
+	})
+
+	It("should wait for the condition of the ansible and helm operators to be true", func() {
+		conditions := func(conditionType, status string) map[string]interface{} {
+			return map[string]interface{}{"conditions": []interface{}{
+				map[string]interface{}{"type": "Running", "status": "True"},
+				map[string]interface{}{"type": conditionType, "status": status},
+			}}
+		}
+
+		ansible := GetReadinessPredicate(AnsibleType)
+		Expect(ansible(withStatus("a", nil, conditions("Successful", "False")))).To(BeFalse())
+		Expect(ansible(withStatus("a", nil, conditions("Successful", "True")))).To(BeTrue())
+		Expect(ansible(withStatus("a", nil, conditions("Deployed", "True")))).To(BeFalse())
+
+		helm := GetReadinessPredicate(HelmType)
+		Expect(helm(withStatus("a", nil, conditions("Deployed", "True")))).To(BeTrue())
+		Expect(helm(withStatus("a", nil, map[string]interface{}{}))).To(BeFalse())
+	})
+
+	It("should only be ready once there are enough ready CRs", func() {
+		ctx := context.TODO()
+		dynamicClient := newFakeDynamicClient()
+		crs := dynamicClient.Resource(memcachedGVR).Namespace(Namespace)
+		ready := ConditionTrue(HelmDeployedCondition)
+		deployed := map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "Deployed", "status": "True"},
+		}}
+
+		_, err := crs.Create(ctx, withStatus("a", nil, deployed), metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = crs.Create(ctx, withStatus("b", nil, map[string]interface{}{}), metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(CRsReady(ctx, dynamicClient, memcachedGVR, Namespace, 2, ready)).To(MatchError("1 of 2 CRs are ready"))
+
+		_, err = crs.Update(ctx, withStatus("b", nil, deployed), metav1.UpdateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(CRsReady(ctx, dynamicClient, memcachedGVR, Namespace, 2, ready)).To(Succeed())
+	})
+})
@@ -0,0 +1,243 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"sync"
+	"time"
+)
+
+// PodDeletedReason is the reason of a restart recorded when a watched pod is deleted, e.g. when it is evicted
+const PodDeletedReason = "PodDeleted"
+
+// ContainerRestart A restart of a container, or deletion of a pod, seen while watching the operator during the run
+type ContainerRestart struct {
+	Pod string `json:"pod"`
+	// Container is empty when the whole pod was deleted
+	Container    string `json:"container,omitempty"`
+	RestartCount int32  `json:"restartCount"`
+	// Reason, ExitCode, StartedAt and FinishedAt are from the last terminated state of the container, e.g. OOMKilled
+	Reason     string      `json:"reason"`
+	ExitCode   int32       `json:"exitCode"`
+	StartedAt  metav1.Time `json:"startedAt,omitempty"`
+	FinishedAt metav1.Time `json:"finishedAt,omitempty"`
+	Phase      Phase       `json:"phase"`
+	// Offset is the number of milliseconds since the start of the run
+	Offset     int64     `json:"offset"`
+	ObservedAt time.Time `json:"observedAt"`
+}
+
+// RestartWatcher Watch the pods of the operator for container restarts and pod deletions, which make the
+// cpu and memory trace of a run misleading
+type RestartWatcher struct {
+	clientset     kubernetes.Interface
+	timeline      *Timeline
+	namespace     string
+	labelSelector string
+
+	mu            sync.Mutex
+	restartCounts map[string]int32
+	restarts      []ContainerRestart
+	writer        *StreamWriter
+	writeErr      error
+	stopCh        chan struct{}
+}
+
+// NewRestartWatcher create a watcher for the pods matching the label selector in the namespace
+func NewRestartWatcher(clientset kubernetes.Interface, timeline *Timeline, namespace, labelSelector string) *RestartWatcher {
+	return &RestartWatcher{
+		clientset:     clientset,
+		timeline:      timeline,
+		namespace:     namespace,
+		labelSelector: labelSelector,
+		restartCounts: map[string]int32{},
+	}
+}
+
+// Start watching the pods, streaming each restart to restarts in the results directory dir.
+// Restarts which happened before the watcher started are not recorded
+func (r *RestartWatcher) Start(ctx context.Context, dir string) error {
+	writer, err := NewStreamWriter(fmt.Sprintf("%s/restarts", dir))
+	if err != nil {
+		return err
+	}
+	r.writer = writer
+
+	factory := informers.NewSharedInformerFactoryWithOptions(r.clientset, 0,
+		informers.WithNamespace(r.namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = r.labelSelector
+		}))
+	informer := factory.Core().V1().Pods().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*corev1.Pod); ok {
+				r.update(pod)
+			}
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			if pod, ok := obj.(*corev1.Pod); ok {
+				r.update(pod)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok {
+				r.delete(pod)
+			}
+		},
+	})
+
+	stopCh := make(chan struct{})
+	r.mu.Lock()
+	r.stopCh = stopCh
+	r.mu.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.stop()
+		case <-stopCh:
+		}
+	}()
+	factory.Start(stopCh)
+	for informerType, synced := range factory.WaitForCacheSync(stopCh) {
+		if !synced {
+			return fmt.Errorf("failed to sync informer for %v", informerType)
+		}
+	}
+
+	return nil
+}
+
+// Stop watching the pods and mark the stream complete
+func (r *RestartWatcher) Stop() error {
+	return r.finish(true)
+}
+
+// Close stop watching the pods if Stop was not called and mark the stream incomplete
+func (r *RestartWatcher) Close() error {
+	return r.finish(false)
+}
+
+// Restarts get the restarts seen so far in the order they were seen
+func (r *RestartWatcher) Restarts() []ContainerRestart {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]ContainerRestart{}, r.restarts...)
+}
+
+// InvalidReasons describe each restart seen, a run with any is not valid
+func (r *RestartWatcher) InvalidReasons() []string {
+	var reasons []string
+	for _, restart := range r.Restarts() {
+		if restart.Container == "" {
+			reasons = append(reasons, fmt.Sprintf("pod %s was deleted in phase %s", restart.Pod, restart.Phase))
+			continue
+		}
+		reasons = append(reasons, fmt.Sprintf("container %s of pod %s restarted in phase %s: %s (exit code %d)",
+			restart.Container, restart.Pod, restart.Phase, restart.Reason, restart.ExitCode))
+	}
+
+	return reasons
+}
+
+// update record the containers of the pod whose restart count went up since the pod was last seen
+func (r *RestartWatcher) update(pod *corev1.Pod) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, status := range pod.Status.ContainerStatuses {
+		key := fmt.Sprintf("%s/%s", pod.Name, status.Name)
+		last, seen := r.restartCounts[key]
+		r.restartCounts[key] = status.RestartCount
+		if !seen || status.RestartCount <= last {
+			continue
+		}
+
+		restart := r.newRestart(pod.Name)
+		restart.Container = status.Name
+		restart.RestartCount = status.RestartCount
+		if terminated := status.LastTerminationState.Terminated; terminated != nil {
+			restart.Reason = terminated.Reason
+			restart.ExitCode = terminated.ExitCode
+			restart.StartedAt = terminated.StartedAt
+			restart.FinishedAt = terminated.FinishedAt
+		}
+		r.record(restart)
+	}
+}
+
+// delete record the deletion of the pod
+func (r *RestartWatcher) delete(pod *corev1.Pod) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	restart := r.newRestart(pod.Name)
+	restart.Reason = PodDeletedReason
+	r.record(restart)
+}
+
+// newRestart create a restart of the pod tagged with the current phase
+func (r *RestartWatcher) newRestart(pod string) ContainerRestart {
+	phase, offset := r.timeline.Current()
+
+	return ContainerRestart{
+		Pod:        pod,
+		Phase:      phase,
+		Offset:     offset.Milliseconds(),
+		ObservedAt: time.Now(),
+	}
+}
+
+// record keep the restart and stream it to disk, must be called with the lock held
+func (r *RestartWatcher) record(restart ContainerRestart) {
+	r.restarts = append(r.restarts, restart)
+	if r.writer == nil {
+		return
+	}
+	// Restarts are rare and often precede the run being killed, so they are flushed straight away
+	err := r.writer.Write(restart)
+	if err == nil {
+		err = r.writer.Sync()
+	}
+	if err != nil && r.writeErr == nil {
+		r.writeErr = err
+	}
+}
+
+// stop the informers if they are running
+func (r *RestartWatcher) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopCh != nil {
+		close(r.stopCh)
+		r.stopCh = nil
+	}
+}
+
+// finish stop watching the pods and write the index of the stream
+func (r *RestartWatcher) finish(complete bool) error {
+	r.stop()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.writer == nil {
+		return nil
+	}
+	err := r.writeErr
+	if finishErr := r.writer.Finish(complete, r.timeline); err == nil {
+		err = finishErr
+	}
+
+	return err
+}
@@ -0,0 +1,78 @@
+package testutils
+
+import (
+	"context"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeOperatorPod create an operator pod whose manager container has restarted restartCount times
+func fakeOperatorPod(restartCount int32, lastState corev1.ContainerState) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "manager-pod",
+			Namespace: Namespace,
+			Labels:    map[string]string{"control-plane": "controller-manager"},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:                 ManagerContainerName,
+				RestartCount:         restartCount,
+				LastTerminationState: lastState,
+			}},
+		},
+	}
+}
+
+var _ = Describe("RestartWatcher", func() {
+	var resultsDir string
+
+	BeforeEach(func() {
+		var err error
+		resultsDir, err = os.MkdirTemp("", "results")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Setenv("RESULTS_DIR", resultsDir)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.Unsetenv("RESULTS_DIR")).To(Succeed())
+		Expect(os.RemoveAll(resultsDir)).To(Succeed())
+	})
+
+	It("should record restarts and deletions seen after it started", func() {
+		clientset := fake.NewSimpleClientset(fakeOperatorPod(1, corev1.ContainerState{}))
+		timeline := NewTimeline()
+		watcher := NewRestartWatcher(clientset, timeline, Namespace, OperatorPodLabel)
+		Expect(watcher.Start(context.TODO(), "restarts-test")).To(Succeed())
+		Expect(watcher.Restarts()).To(BeEmpty())
+
+		timeline.Mark(PhaseCreate)
+		finishedAt := metav1.NewTime(time.Now().Truncate(time.Second))
+		_, err := clientset.CoreV1().Pods(Namespace).UpdateStatus(context.TODO(), fakeOperatorPod(2, corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137, FinishedAt: finishedAt},
+		}), metav1.UpdateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(watcher.Restarts).Should(HaveLen(1))
+
+		Expect(clientset.CoreV1().Pods(Namespace).Delete(context.TODO(), "manager-pod", metav1.DeleteOptions{})).To(Succeed())
+		Eventually(watcher.Restarts).Should(HaveLen(2))
+		Expect(watcher.Stop()).To(Succeed())
+
+		restarts := watcher.Restarts()
+		Expect(restarts[0].Container).To(Equal(ManagerContainerName))
+		Expect(restarts[0].RestartCount).To(Equal(int32(2)))
+		Expect(restarts[0].Reason).To(Equal("OOMKilled"))
+		Expect(restarts[0].ExitCode).To(Equal(int32(137)))
+		Expect(restarts[0].FinishedAt.Time).To(BeTemporally("==", finishedAt.Time))
+		Expect(restarts[0].Phase).To(Equal(PhaseCreate))
+		Expect(restarts[1].Container).To(BeEmpty())
+		Expect(restarts[1].Reason).To(Equal(PodDeletedReason))
+		Expect(watcher.InvalidReasons()).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,376 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	"sync"
+	"time"
+)
+
+// NodesRole is the role sample stats of the cluster nodes are reported under
+const NodesRole = "nodes"
+
+// PodSample Pod metrics tagged with the phase of the run it was gathered in
+type PodSample struct {
+	v1beta1.PodMetrics
+	Role  string `json:"role"`
+	Phase Phase  `json:"phase"`
+	// Offset is the number of milliseconds since the start of the run
+	Offset int64 `json:"offset"`
+	// ObservedAt is when the sampler first saw the sample, Timestamp is when it was measured
+	ObservedAt time.Time `json:"observedAt"`
+	// Stats and ContainerStats are only set by metrics sources which provide more than cpu and memory usage
+	Stats          *UsageStats  `json:"stats,omitempty"`
+	ContainerStats []UsageStats `json:"containerStats,omitempty"`
+}
+
+// NodeSample Node metrics tagged with the phase of the run it was gathered in
+type NodeSample struct {
+	v1beta1.NodeMetrics
+	Phase Phase `json:"phase"`
+	// Offset is the number of milliseconds since the start of the run
+	Offset int64 `json:"offset"`
+	// ObservedAt is when the sampler first saw the sample, Timestamp is when it was measured
+	ObservedAt time.Time `json:"observedAt"`
+	// Stats is only set by metrics sources which provide more than cpu and memory usage
+	Stats *UsageStats `json:"stats,omitempty"`
+}
+
+// SampleStats How many distinct samples were gathered for a role compared to how often the source was polled
+type SampleStats struct {
+	Role string `json:"role"`
+	// Polls is the number of times the source returned metrics for the role
+	Polls int `json:"polls"`
+	// Observed is the number of samples returned, including repeats of samples already seen
+	Observed int `json:"observed"`
+	// Distinct is the number of samples kept after dropping repeats
+	Distinct int `json:"distinct"`
+	// Sources is the number of pods or nodes samples were gathered for
+	Sources         int     `json:"sources"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	// EffectiveSampleRate is the number of distinct samples per pod or node per second
+	EffectiveSampleRate float64 `json:"effectiveSampleRate"`
+}
+
+// UsagePoint The cpu and memory usage of a container in a distinct pod sample
+type UsagePoint struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Phase     Phase  `json:"phase"`
+	// Offset is the number of milliseconds since the start of the run the sample was observed at
+	Offset        int64 `json:"offset"`
+	CPUMillicores int64 `json:"cpuMillicores"`
+	MemoryBytes   int64 `json:"memoryBytes"`
+}
+
+// Sampler Continuously gather pod and node metrics over the whole run, streaming each sample to disk as it arrives.
+// Sources such as metrics-server refresh far less often than the sampler polls, so a sample with the same timestamp
+// and window as the last one of the same pod or node is dropped rather than saved again
+type Sampler struct {
+	source   MetricsSource
+	timeline *Timeline
+	targets  []SampleTarget
+
+	mu        sync.Mutex
+	writers   map[string]*StreamWriter
+	writeErr  error
+	lastPhase Phase
+	last      map[string]sampleWindow
+	stats     map[string]*SampleStats
+	sources   map[string]map[string]struct{}
+	usage     map[usageKey][]UsagePoint
+	started   time.Time
+	stopped   time.Time
+
+	loop *backgroundLoop
+}
+
+// sampleWindow When a sample was measured and over how long
+type sampleWindow struct {
+	timestamp time.Time
+	window    time.Duration
+}
+
+// usageKey A container of the pods of a target whose usage is kept in memory
+type usageKey struct {
+	role      string
+	container string
+}
+
+// NewSampler create a sampler gathering metrics of the targets from the source, labelled using the timeline phases
+func NewSampler(source MetricsSource, timeline *Timeline, targets []SampleTarget) *Sampler {
+	return &Sampler{
+		source:   source,
+		timeline: timeline,
+		targets:  targets,
+		writers:  map[string]*StreamWriter{},
+		last:     map[string]sampleWindow{},
+		stats:    map[string]*SampleStats{},
+		sources:  map[string]map[string]struct{}{},
+		usage:    map[usageKey][]UsagePoint{},
+	}
+}
+
+// TrackUsage keep the usage of the container in the pods of the target with the role in memory, so UsageSeries can
+// return it. Must be called before Start
+func (s *Sampler) TrackUsage(role, container string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := usageKey{role: role, container: container}
+	if _, ok := s.usage[key]; !ok {
+		s.usage[key] = []UsagePoint{}
+	}
+}
+
+// Start gathering metrics in the background until the context is cancelled or Stop is called.
+// The samples of each target are streamed to its series directory in the results directory dir, and the node
+// samples to cpuMemory-nodes. If a stream cannot be opened, the streams already opened are closed and marked
+// incomplete
+func (s *Sampler) Start(ctx context.Context, dir string) error {
+	for _, target := range s.targets {
+		if err := s.openWriter(target.Role, fmt.Sprintf("%s/%s", dir, target.SeriesDir())); err != nil {
+			_ = s.finish(false)
+			return err
+		}
+	}
+	if err := s.openWriter(NodesRole, fmt.Sprintf("%s/cpuMemory-%s", dir, NodesRole)); err != nil {
+		_ = s.finish(false)
+		return err
+	}
+
+	s.started = time.Now()
+	s.lastPhase, _ = s.timeline.Current()
+	s.loop = startLoop(ctx, tickerInterval, func(ctx context.Context) {
+		s.syncOnPhaseChange()
+		for _, target := range s.targets {
+			s.samplePods(ctx, target)
+		}
+		s.sampleNodes(ctx)
+	})
+
+	return nil
+}
+
+// Stop gathering metrics and mark the streams complete.
+// Returns the first error writing samples to disk
+func (s *Sampler) Stop() error {
+	return s.finish(true)
+}
+
+// Close stop gathering metrics if Stop was not called and mark the streams incomplete, so the samples of a failed
+// run are kept but not mistaken for a complete run
+func (s *Sampler) Close() error {
+	return s.finish(false)
+}
+
+// Targets get the targets the sampler gathers pod metrics for
+func (s *Sampler) Targets() []SampleTarget {
+	return s.targets
+}
+
+// Stats get the sample stats of each target followed by the nodes
+func (s *Sampler) Stats() []SampleStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	end := s.stopped
+	if end.IsZero() {
+		end = time.Now()
+	}
+	duration := end.Sub(s.started).Seconds()
+
+	roles := make([]string, 0, len(s.targets)+1)
+	for _, target := range s.targets {
+		roles = append(roles, target.Role)
+	}
+	roles = append(roles, NodesRole)
+
+	stats := make([]SampleStats, 0, len(roles))
+	for _, role := range roles {
+		roleStats := SampleStats{Role: role}
+		if st, ok := s.stats[role]; ok {
+			roleStats = *st
+		}
+		roleStats.Sources = len(s.sources[role])
+		roleStats.DurationSeconds = duration
+		if roleStats.Sources > 0 && duration > 0 {
+			roleStats.EffectiveSampleRate = float64(roleStats.Distinct) / float64(roleStats.Sources) / duration
+		}
+		stats = append(stats, roleStats)
+	}
+
+	return stats
+}
+
+// UsageSeries get the cpu and memory usage of the container in every distinct sample of a target, kept in memory so
+// trends can be checked while the run is going. Empty unless the container was tracked with TrackUsage
+func (s *Sampler) UsageSeries(role, container string) []UsagePoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	points := s.usage[usageKey{role: role, container: container}]
+	series := make([]UsagePoint, len(points))
+	copy(series, points)
+
+	return series
+}
+
+// samplePods gather a single set of pod metrics for a target
+func (s *Sampler) samplePods(ctx context.Context, target SampleTarget) {
+	samples, err := s.source.PodMetrics(ctx, target)
+	if err != nil {
+		return
+	}
+
+	phase, offset := s.timeline.Current()
+	observedAt := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.roleStats(target.Role)
+	stats.Polls++
+	for _, sample := range samples {
+		stats.Observed++
+		source := fmt.Sprintf("%s/%s", sample.Namespace, sample.Name)
+		if !s.isNew(target.Role, source, sample.Timestamp.Time, sample.Window.Duration) {
+			continue
+		}
+		stats.Distinct++
+
+		sortContainersByName(sample.Containers)
+		sample.Role = target.Role
+		sample.Phase = phase
+		sample.Offset = offset.Milliseconds()
+		sample.ObservedAt = observedAt
+		s.write(target.Role, sample)
+		for _, container := range sample.Containers {
+			key := usageKey{role: target.Role, container: container.Name}
+			if _, ok := s.usage[key]; !ok {
+				continue
+			}
+			s.usage[key] = append(s.usage[key], UsagePoint{
+				Pod:           sample.Name,
+				Container:     container.Name,
+				Phase:         sample.Phase,
+				Offset:        sample.Offset,
+				CPUMillicores: container.Usage.Cpu().MilliValue(),
+				MemoryBytes:   container.Usage.Memory().Value(),
+			})
+		}
+	}
+}
+
+// sampleNodes gather a single set of node metrics
+func (s *Sampler) sampleNodes(ctx context.Context) {
+	samples, err := s.source.NodeMetrics(ctx)
+	if err != nil {
+		return
+	}
+
+	phase, offset := s.timeline.Current()
+	observedAt := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.roleStats(NodesRole)
+	stats.Polls++
+	for _, sample := range samples {
+		stats.Observed++
+		if !s.isNew(NodesRole, sample.Name, sample.Timestamp.Time, sample.Window.Duration) {
+			continue
+		}
+		stats.Distinct++
+
+		sample.Phase = phase
+		sample.Offset = offset.Milliseconds()
+		sample.ObservedAt = observedAt
+		s.write(NodesRole, sample)
+	}
+}
+
+// openWriter open the stream the samples of a role are written to
+func (s *Sampler) openWriter(role, dir string) error {
+	writer, err := NewStreamWriter(dir)
+	if err != nil {
+		return err
+	}
+	s.writers[role] = writer
+
+	return nil
+}
+
+// write stream the sample of a role to disk, keeping the first error, must be called with the lock held
+func (s *Sampler) write(role string, sample interface{}) {
+	if err := s.writers[role].Write(sample); err != nil && s.writeErr == nil {
+		s.writeErr = err
+	}
+}
+
+// syncOnPhaseChange flush the streams to disk when the run has moved to a new phase
+func (s *Sampler) syncOnPhaseChange() {
+	phase, _ := s.timeline.Current()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if phase == s.lastPhase {
+		return
+	}
+	s.lastPhase = phase
+	for _, writer := range s.writers {
+		if err := writer.Sync(); err != nil && s.writeErr == nil {
+			s.writeErr = err
+		}
+	}
+}
+
+// finish stop gathering metrics and write the index of each stream
+func (s *Sampler) finish(complete bool) error {
+	s.loop.stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped.IsZero() {
+		s.stopped = time.Now()
+	}
+	err := s.writeErr
+	for _, writer := range s.writers {
+		if finishErr := writer.Finish(complete, s.timeline); err == nil {
+			err = finishErr
+		}
+	}
+
+	return err
+}
+
+// roleStats get the sample stats of a role, must be called with the lock held
+func (s *Sampler) roleStats(role string) *SampleStats {
+	if _, ok := s.stats[role]; !ok {
+		s.stats[role] = &SampleStats{Role: role}
+	}
+
+	return s.stats[role]
+}
+
+// isNew record the sample of the source and report whether it differs from the last one, must be called with the
+// lock held
+func (s *Sampler) isNew(role, source string, timestamp time.Time, window time.Duration) bool {
+	if _, ok := s.sources[role]; !ok {
+		s.sources[role] = map[string]struct{}{}
+	}
+	s.sources[role][source] = struct{}{}
+
+	key := fmt.Sprintf("%s/%s", role, source)
+	current := sampleWindow{timestamp: timestamp, window: window}
+	if last, ok := s.last[key]; ok && last.timestamp.Equal(timestamp) && last.window == window {
+		return false
+	}
+	s.last[key] = current
+
+	return true
+}
@@ -0,0 +1,172 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeMetricsSource return the next set of samples each time it is polled
+type fakeMetricsSource struct {
+	pods  [][]PodSample
+	nodes [][]NodeSample
+}
+
+func (f *fakeMetricsSource) PodMetrics(_ context.Context, _ SampleTarget) ([]PodSample, error) {
+	samples := f.pods[0]
+	f.pods = f.pods[1:]
+	return samples, nil
+}
+
+func (f *fakeMetricsSource) NodeMetrics(_ context.Context) ([]NodeSample, error) {
+	samples := f.nodes[0]
+	f.nodes = f.nodes[1:]
+	return samples, nil
+}
+
+func fakePodSample(name string, timestamp time.Time) PodSample {
+	return PodSample{PodMetrics: v1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: Namespace},
+		Timestamp:  metav1.NewTime(timestamp),
+		Window:     metav1.Duration{Duration: 15 * time.Second},
+	}}
+}
+
+var _ = Describe("Sampler", func() {
+	measured := time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC)
+
+	var resultsDir string
+
+	BeforeEach(func() {
+		var err error
+		resultsDir, err = os.MkdirTemp("", "results")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Setenv("RESULTS_DIR", resultsDir)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.Unsetenv("RESULTS_DIR")).To(Succeed())
+		Expect(os.RemoveAll(resultsDir)).To(Succeed())
+	})
+
+	It("should stream distinct samples and report the effective sample rate", func() {
+		source := &fakeMetricsSource{
+			pods: [][]PodSample{
+				{fakePodSample("manager", measured)},
+				{fakePodSample("manager", measured)},
+				{fakePodSample("manager", measured.Add(15*time.Second))},
+			},
+			nodes: [][]NodeSample{
+				{{NodeMetrics: v1beta1.NodeMetrics{ObjectMeta: metav1.ObjectMeta{Name: "kind-control-plane"}}}},
+			},
+		}
+		target := SampleTarget{Role: RoleOperator, Namespace: Namespace, LabelSelector: OperatorPodLabel}
+		sampler := NewSampler(source, NewTimeline(), []SampleTarget{target})
+		Expect(sampler.openWriter(RoleOperator, "run/cpuMemory")).To(Succeed())
+		Expect(sampler.openWriter(NodesRole, "run/cpuMemory-nodes")).To(Succeed())
+		sampler.started = time.Now().Add(-10 * time.Second)
+
+		for i := 0; i < 3; i++ {
+			sampler.samplePods(context.TODO(), target)
+		}
+		sampler.sampleNodes(context.TODO())
+		Expect(sampler.Stop()).To(Succeed())
+
+		files, err := filepath.Glob(filepath.Join(resultsDir, "run", "cpuMemory", "*.ndjson"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		records, index, err := LoadStream(files[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(index).NotTo(BeNil())
+		Expect(index.Complete).To(BeTrue())
+		Expect(index.Records).To(Equal(2))
+		Expect(index.Phases).To(HaveLen(1))
+
+		samples := make([]PodSample, len(records))
+		for i, record := range records {
+			Expect(json.Unmarshal(record, &samples[i])).To(Succeed())
+		}
+		Expect(samples).To(HaveLen(2))
+		Expect(samples[0].Phase).To(Equal(PhaseBaseline))
+		Expect(samples[0].ObservedAt).NotTo(BeZero())
+		Expect(samples[1].Timestamp.Time).To(BeTemporally("==", measured.Add(15*time.Second)))
+
+		stats := sampler.Stats()
+		Expect(stats).To(HaveLen(2))
+		Expect(stats[0].Role).To(Equal(RoleOperator))
+		Expect(stats[0].Polls).To(Equal(3))
+		Expect(stats[0].Observed).To(Equal(3))
+		Expect(stats[0].Distinct).To(Equal(2))
+		Expect(stats[0].Sources).To(Equal(1))
+		Expect(stats[0].EffectiveSampleRate).To(BeNumerically("~", 0.2, 0.01))
+		Expect(stats[1].Role).To(Equal(NodesRole))
+		Expect(stats[1].Distinct).To(Equal(1))
+		// Only the last sample of each pod and node is kept to drop repeats
+		Expect(sampler.last).To(HaveLen(2))
+	})
+
+	It("should close the streams already opened when one cannot be", func() {
+		Expect(os.MkdirAll(filepath.Join(resultsDir, "run"), 0755)).To(Succeed())
+		// A file in place of the nodes directory makes opening that stream fail
+		Expect(os.WriteFile(filepath.Join(resultsDir, "run", "cpuMemory-"+NodesRole), nil, 0644)).To(Succeed())
+		target := SampleTarget{Role: RoleOperator, Namespace: Namespace, LabelSelector: OperatorPodLabel}
+		sampler := NewSampler(&fakeMetricsSource{}, NewTimeline(), []SampleTarget{target})
+
+		Expect(sampler.Start(context.TODO(), "run")).NotTo(Succeed())
+		files, err := filepath.Glob(filepath.Join(resultsDir, "run", target.SeriesDir(), "*.ndjson"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		_, index, err := LoadStream(files[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(index).NotTo(BeNil())
+		Expect(index.Complete).To(BeFalse())
+	})
+
+	It("should keep the usage of the tracked containers of distinct samples", func() {
+		withContainers := func(sample PodSample, manager, proxy string) PodSample {
+			sample.Containers = []v1beta1.ContainerMetrics{
+				{Name: "kube-rbac-proxy", Usage: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(proxy)}},
+				{Name: ManagerContainerName, Usage: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("250m"),
+					corev1.ResourceMemory: resource.MustParse(manager),
+				}},
+			}
+			return sample
+		}
+		source := &fakeMetricsSource{
+			pods: [][]PodSample{
+				{withContainers(fakePodSample("manager", measured), "40Mi", "10Mi")},
+				{withContainers(fakePodSample("manager", measured), "40Mi", "10Mi")},
+				{withContainers(fakePodSample("manager", measured.Add(15*time.Second)), "41Mi", "10Mi")},
+			},
+		}
+		target := SampleTarget{Role: RoleOperator, Namespace: Namespace, LabelSelector: OperatorPodLabel}
+		sampler := NewSampler(source, NewTimeline(), []SampleTarget{target})
+		sampler.TrackUsage(RoleOperator, ManagerContainerName)
+		Expect(sampler.openWriter(RoleOperator, "run/cpuMemory")).To(Succeed())
+
+		for i := 0; i < 3; i++ {
+			sampler.samplePods(context.TODO(), target)
+		}
+
+		series := sampler.UsageSeries(RoleOperator, ManagerContainerName)
+		Expect(series).To(HaveLen(2))
+		Expect(series[0].Pod).To(Equal("manager"))
+		Expect(series[0].CPUMillicores).To(Equal(int64(250)))
+		Expect(series[0].MemoryBytes).To(Equal(int64(40 * 1024 * 1024)))
+		Expect(series[1].MemoryBytes).To(Equal(int64(41 * 1024 * 1024)))
+		Expect(sampler.UsageSeries(RoleOperand, ManagerContainerName)).To(BeEmpty())
+		Expect(sampler.UsageSeries(RoleOperator, "kube-rbac-proxy")).To(BeEmpty())
+		Expect(sampler.usage).To(HaveLen(1))
+	})
+})
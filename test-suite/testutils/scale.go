@@ -0,0 +1,221 @@
+package testutils
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	ScaleUp   = "up"
+	ScaleDown = "down"
+	// DefaultUpdateSize is the size CRs are scaled up to in the update phase when the operands are memcached
+	DefaultUpdateSize = 2
+)
+
+// CRScale When a CR was asked to scale and when the Deployments or StatefulSets it owns had the new number of replicas
+// ready, in milliseconds since the start of the run
+type CRScale struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Label is up or down
+	Label     string `json:"label"`
+	Replicas  int32  `json:"replicas"`
+	Requested *int64 `json:"requested,omitempty"`
+	Reached   *int64 `json:"reached,omitempty"`
+}
+
+// crScale A scale of a CR being tracked
+type crScale struct {
+	label       string
+	replicas    int32
+	requestedAt time.Time
+	reachedAt   time.Time
+}
+
+// SizeField get the path of the field setting the number of memcached pods in the sample of the operator type
+func SizeField(oType string) []string {
+	if oType == HelmType {
+		return []string{"spec", "replicaCount"}
+	}
+
+	return []string{"spec", "size"}
+}
+
+// SizeOf get the size set in the CR, which defaults to 1
+func SizeOf(cr *unstructured.Unstructured, oType string) (int64, error) {
+	size, found, err := unstructured.NestedInt64(cr.Object, SizeField(oType)...)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 1, nil
+	}
+
+	return size, nil
+}
+
+// WithSize copy the CR with its size set
+func WithSize(cr *unstructured.Unstructured, oType string, size int64) (*unstructured.Unstructured, error) {
+	sized := cr.DeepCopy()
+	if err := unstructured.SetNestedField(sized.Object, size, SizeField(oType)...); err != nil {
+		return nil, err
+	}
+
+	return sized, nil
+}
+
+// GetUpdateSize get the size to scale CRs up to in the update phase from the UPDATE_SIZE env variable, 0 skips the
+// update phase. The update phase is skipped by default unless the operands are memcached, as it would otherwise run
+// the pods the operand mode avoids
+func GetUpdateSize(operandMode string) (int64, error) {
+	defaultSize := DefaultUpdateSize
+	if operandMode != OperandMemcached {
+		defaultSize = 0
+	}
+	size, err := intFromEnv("UPDATE_SIZE", defaultSize)
+	if err != nil {
+		return 0, err
+	}
+	if size < 0 {
+		return 0, fmt.Errorf("UPDATE_SIZE must not be negative, got %d", size)
+	}
+
+	return int64(size), nil
+}
+
+// GetUpdatePattern get when to send the update of each of count CRs. Updates are sent one after another unless
+// UPDATE_RATE sets the number sent per second
+func GetUpdatePattern(count int) (ArrivalPattern, error) {
+	if os.Getenv("UPDATE_RATE") == "" {
+		return ArrivalPattern{Model: ArrivalBurst, Count: count, Workers: 1}, nil
+	}
+
+	rate, err := floatFromEnv("UPDATE_RATE", 0)
+	if err != nil {
+		return ArrivalPattern{}, err
+	}
+	pattern := ArrivalPattern{Model: ArrivalRate, Count: count, Rate: rate}
+
+	return pattern, pattern.Validate()
+}
+
+// ScaleRequested record when the test asked the CR to scale to replicas
+func (l *LifecycleTracker) ScaleRequested(key types.NamespacedName, label string, replicas int32, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.scales[key] = append(l.scales[key], &crScale{label: label, replicas: replicas, requestedAt: at})
+	if uid, ok := l.current[key]; ok {
+		l.checkCRScales(key, uid, at)
+	}
+}
+
+// PendingScales get the number of scales requested which have not been reached yet
+func (l *LifecycleTracker) PendingScales() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pending := 0
+	for _, scales := range l.scales {
+		for _, scale := range scales {
+			if scale.reachedAt.IsZero() {
+				pending++
+			}
+		}
+	}
+
+	return pending
+}
+
+// Scales get every scale requested in the order they were requested
+func (l *LifecycleTracker) Scales() []CRScale {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	scales := []CRScale{}
+	for key, crScales := range l.scales {
+		for _, scale := range crScales {
+			scales = append(scales, CRScale{
+				Namespace: key.Namespace,
+				Name:      key.Name,
+				Label:     scale.label,
+				Replicas:  scale.replicas,
+				Requested: l.offset(scale.requestedAt),
+				Reached:   l.offset(scale.reachedAt),
+			})
+		}
+	}
+	sort.Slice(scales, func(i, j int) bool {
+		if *scales[i].Requested != *scales[j].Requested {
+			return *scales[i].Requested < *scales[j].Requested
+		}
+		if scales[i].Namespace != scales[j].Namespace {
+			return scales[i].Namespace < scales[j].Namespace
+		}
+		return scales[i].Name < scales[j].Name
+	})
+
+	return scales
+}
+
+// checkScales check whether the CRs owning a Deployment or StatefulSet which changed reached the replicas they were
+// asked to scale to, must be called with the lock held
+func (l *LifecycleTracker) checkScales(object *trackedObject, now time.Time) {
+	for _, owner := range object.owners {
+		if cr, ok := l.crs[owner]; ok && l.current[cr.key] == owner {
+			l.checkCRScales(cr.key, owner, now)
+		}
+	}
+}
+
+// checkCRScales mark the pending scales of a CR reached if its Deployments and StatefulSets have rolled out the
+// replicas they were asked for and have them ready, must be called with the lock held
+func (l *LifecycleTracker) checkCRScales(key types.NamespacedName, uid types.UID, now time.Time) {
+	pending := false
+	for _, scale := range l.scales[key] {
+		pending = pending || scale.reachedAt.IsZero()
+	}
+	if !pending {
+		return
+	}
+
+	var replicas, ready int32
+	workloads := 0
+	for child := range l.children[uid] {
+		object := l.objects[child]
+		if (object.kind != "Deployment" && object.kind != "StatefulSet") || !object.goneAt.IsZero() {
+			continue
+		}
+		if !object.rolledOut {
+			return
+		}
+		workloads++
+		replicas += object.replicas
+		ready += object.readyReplicas
+	}
+	if workloads == 0 || replicas != ready {
+		return
+	}
+
+	for _, scale := range l.scales[key] {
+		if scale.reachedAt.IsZero() && scale.replicas == replicas && !scale.requestedAt.After(now) {
+			scale.reachedAt = now
+		}
+	}
+}
+
+// ScaleLatencies get the percentiles of the time from CRs being asked to scale to reaching the replicas, by label
+func ScaleLatencies(scales []CRScale) map[string]LatencyPercentiles {
+	latencies := map[string][]float64{}
+	for _, scale := range scales {
+		if scale.Requested != nil && scale.Reached != nil {
+			latencies[scale.Label] = append(latencies[scale.Label], float64(*scale.Reached-*scale.Requested))
+		}
+	}
+
+	return latencyPercentiles(latencies)
+}
@@ -0,0 +1,116 @@
+package testutils
+
+import (
+	"context"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Scale", func() {
+	It("should set the size field of the operator type", func() {
+		sample := fakeMemcached("memcached-sample", "")
+		size, err := SizeOf(sample, HelmType)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(int64(1)))
+
+		sized, err := WithSize(sample, HelmType, 3)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sized.Object["spec"]).To(Equal(map[string]interface{}{"replicaCount": int64(3)}))
+		Expect(sample.Object).NotTo(HaveKey("spec"))
+
+		size, err = SizeOf(sized, HelmType)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(int64(3)))
+		size, err = SizeOf(sized, GoType)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(int64(1)))
+	})
+
+	It("should skip the update phase by default unless the operands are memcached", func() {
+		size, err := GetUpdateSize(OperandMemcached)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(int64(DefaultUpdateSize)))
+		size, err = GetUpdateSize(OperandZero)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(BeZero())
+
+		Expect(os.Setenv("UPDATE_SIZE", "3")).To(Succeed())
+		defer os.Unsetenv("UPDATE_SIZE")
+		size, err = GetUpdateSize(OperandPause)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(int64(3)))
+	})
+
+	It("should pace updates with UPDATE_RATE", func() {
+		pattern, err := GetUpdatePattern(15)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pattern).To(Equal(ArrivalPattern{Model: ArrivalBurst, Count: 15, Workers: 1}))
+
+		Expect(os.Setenv("UPDATE_RATE", "5")).To(Succeed())
+		defer os.Unsetenv("UPDATE_RATE")
+		pattern, err = GetUpdatePattern(15)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pattern).To(Equal(ArrivalPattern{Model: ArrivalRate, Count: 15, Rate: 5}))
+	})
+
+	It("should record when the Deployment of a CR has the replicas it was scaled to", func() {
+		ctx := context.TODO()
+		clientset := fake.NewSimpleClientset()
+		dynamicClient := newFakeDynamicClient()
+		timeline := NewTimeline()
+		tracker := NewLifecycleTracker(clientset, dynamicClient, memcachedGVR, StatusNodesReady, timeline, Namespace)
+		Expect(tracker.Start(ctx)).To(Succeed())
+		defer tracker.Stop()
+
+		_, err := dynamicClient.Resource(memcachedGVR).Namespace(Namespace).Create(ctx,
+			fakeMemcached("memcached-sample00", "cr"), metav1.CreateOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		deployments := clientset.AppsV1().Deployments(Namespace)
+		setDeployment := func(generation, observedGeneration int64, replicas, ready int32) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: ownedBy("memcached-sample00", "deployment", "cr"),
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status:     appsv1.DeploymentStatus{ObservedGeneration: observedGeneration, ReadyReplicas: ready},
+			}
+			deployment.Generation = generation
+			if generation == 1 {
+				_, err = deployments.Create(ctx, deployment, metav1.CreateOptions{})
+			} else {
+				_, err = deployments.Update(ctx, deployment, metav1.UpdateOptions{})
+			}
+			Expect(err).NotTo(HaveOccurred())
+			time.Sleep(5 * time.Millisecond)
+		}
+		setDeployment(1, 1, 1, 1)
+		Eventually(tracker.Lifecycles).Should(ContainElement(HaveField("OwnedCreated", Not(BeNil()))))
+
+		tracker.ScaleRequested(sample00, ScaleUp, 2, time.Now())
+		setDeployment(2, 1, 2, 1)
+		setDeployment(2, 2, 2, 1)
+		Consistently(tracker.PendingScales, 50*time.Millisecond).Should(Equal(1))
+		setDeployment(2, 2, 2, 2)
+		Eventually(tracker.PendingScales).Should(BeZero())
+
+		tracker.ScaleRequested(sample00, ScaleDown, 1, time.Now())
+		setDeployment(3, 3, 1, 1)
+		Eventually(tracker.PendingScales).Should(BeZero())
+
+		scales := tracker.Scales()
+		Expect(scales).To(HaveLen(2))
+		Expect(scales[0].Label).To(Equal(ScaleUp))
+		Expect(scales[1].Label).To(Equal(ScaleDown))
+		for _, scale := range scales {
+			Expect(*scale.Reached).To(BeNumerically(">=", *scale.Requested))
+		}
+
+		latencies := ScaleLatencies(scales)
+		Expect(latencies).To(HaveLen(2))
+		Expect(latencies[ScaleUp].P50).To(Equal(float64(*scales[0].Reached - *scales[0].Requested)))
+	})
+})
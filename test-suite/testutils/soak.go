@@ -0,0 +1,346 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"math"
+	"time"
+)
+
+const (
+	DefaultSoakWarmup   = 30 * time.Minute
+	DefaultSoakInterval = time.Minute
+	DefaultSoakChurn    = 0.1
+	// DefaultSoakMaxSlope is 8MiB of memory growth per hour
+	DefaultSoakMaxSlope = 8 * 1024 * 1024
+)
+
+// SoakConfig How long to keep the CR population churning and how much memory growth is tolerated once warmed up,
+// no soak is run if Duration is 0. Durations are saved in nanoseconds
+type SoakConfig struct {
+	Duration time.Duration `json:"duration"`
+	// Warmup is how long after the soak started memory is left out of the trend, while caches fill up
+	Warmup   time.Duration `json:"warmup"`
+	Interval time.Duration `json:"interval"`
+	// Churn is the share of the population deleted, created and updated every interval
+	Churn    float64 `json:"churn"`
+	MaxSlope float64 `json:"maxSlopeBytesPerHour"`
+}
+
+// SoakCycle The CRs churned in one interval of the soak
+type SoakCycle struct {
+	Cycle int `json:"cycle"`
+	// Offset is the number of milliseconds since the start of the run the cycle started at
+	Offset     int64    `json:"offset"`
+	DurationMs int64    `json:"durationMs"`
+	Deleted    []string `json:"deleted"`
+	Created    []string `json:"created"`
+	Updated    []string `json:"updated"`
+}
+
+// MemoryTrend The least squares line through the memory of a container after the warmup
+type MemoryTrend struct {
+	Container string `json:"container"`
+	// FromOffset is the number of milliseconds since the start of the run the fitted points start at
+	FromOffset        int64   `json:"fromOffset"`
+	Points            int     `json:"points"`
+	SlopeBytesPerHour float64 `json:"slopeBytesPerHour"`
+	// InterceptBytes is the memory the line gives at FromOffset
+	InterceptBytes       float64 `json:"interceptBytes"`
+	R2                   float64 `json:"r2"`
+	MaxSlopeBytesPerHour float64 `json:"maxSlopeBytesPerHour"`
+	Leaking              bool    `json:"leaking"`
+}
+
+// SoakSummary The cycles of a soak and the memory trend of the operator over it
+type SoakSummary struct {
+	RunID  string      `json:"runId"`
+	Config SoakConfig  `json:"config"`
+	Cycles []SoakCycle `json:"cycles"`
+	Trend  MemoryTrend `json:"trend"`
+}
+
+// GetSoakConfig get the soak from the SOAK_DURATION, SOAK_WARMUP, SOAK_INTERVAL, SOAK_CHURN and SOAK_MAX_SLOPE env
+// variables
+func GetSoakConfig() (SoakConfig, error) {
+	config := SoakConfig{}
+
+	var err error
+	if config.Duration, err = durationFromEnv("SOAK_DURATION", 0); err != nil {
+		return config, err
+	}
+	if config.Warmup, err = durationFromEnv("SOAK_WARMUP", DefaultSoakWarmup); err != nil {
+		return config, err
+	}
+	if config.Interval, err = durationFromEnv("SOAK_INTERVAL", DefaultSoakInterval); err != nil {
+		return config, err
+	}
+	if config.Churn, err = floatFromEnv("SOAK_CHURN", DefaultSoakChurn); err != nil {
+		return config, err
+	}
+	if config.MaxSlope, err = floatFromEnv("SOAK_MAX_SLOPE", DefaultSoakMaxSlope); err != nil {
+		return config, err
+	}
+
+	return config, config.Validate()
+}
+
+// Validate check the soak leaves time to fit a trend after the warmup and churns a share of the population
+func (c SoakConfig) Validate() error {
+	if c.Duration < 0 {
+		return fmt.Errorf("SOAK_DURATION must not be negative")
+	}
+	if !c.Enabled() {
+		return nil
+	}
+	if c.Warmup < 0 || c.Warmup >= c.Duration {
+		return fmt.Errorf("SOAK_WARMUP must not be negative and must be shorter than SOAK_DURATION")
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("SOAK_INTERVAL must be positive")
+	}
+	if c.Churn <= 0 || c.Churn > 1 {
+		return fmt.Errorf("SOAK_CHURN must be greater than 0 and at most 1")
+	}
+	if c.MaxSlope <= 0 {
+		return fmt.Errorf("SOAK_MAX_SLOPE must be positive")
+	}
+
+	return nil
+}
+
+// Enabled check whether a soak should be run
+func (c SoakConfig) Enabled() bool {
+	return c.Duration > 0
+}
+
+// ChurnCount get the number of CRs of the population churned every interval, at least one
+func (c SoakConfig) ChurnCount(population int) int {
+	count := int(math.Ceil(c.Churn * float64(population)))
+	if count < 1 {
+		count = 1
+	}
+	if count > population {
+		count = population
+	}
+
+	return count
+}
+
+// Sizes get the sizes the soak resizes CRs of size original to. CRs of size 0 are not resized, so the soak does not
+// run operand pods when there are none
+func (c SoakConfig) Sizes(original int64) []int64 {
+	if !c.Enabled() || original == 0 {
+		return nil
+	}
+
+	return []int64{original + 1}
+}
+
+// Soak Keeps the number of CRs steady while replacing and resizing a share of them every interval, so caches keyed
+// by CR see a constant stream of new objects without the population growing
+type Soak struct {
+	config   SoakConfig
+	workload *WorkloadDriver
+	timeline *Timeline
+	sample   *unstructured.Unstructured
+	oType    string
+	cr       func(i int) types.NamespacedName
+
+	original   int64
+	next       int
+	population []types.NamespacedName
+	sizes      map[types.NamespacedName]int64
+	created    []types.NamespacedName
+	deleted    []types.NamespacedName
+	cycles     []SoakCycle
+}
+
+// NewSoak create a soak churning the population of CRs copied from the sample, new CRs are named and placed using the
+// index following the population
+func NewSoak(config SoakConfig, workload *WorkloadDriver, timeline *Timeline, sample *unstructured.Unstructured,
+	oType string, population []types.NamespacedName, cr func(i int) types.NamespacedName) (*Soak, error) {
+	original, err := SizeOf(sample, oType)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[types.NamespacedName]int64, len(population))
+	for _, key := range population {
+		sizes[key] = original
+	}
+
+	return &Soak{
+		config:     config,
+		workload:   workload,
+		timeline:   timeline,
+		sample:     sample,
+		oType:      oType,
+		cr:         cr,
+		original:   original,
+		next:       len(population),
+		population: append([]types.NamespacedName{}, population...),
+		sizes:      sizes,
+	}, nil
+}
+
+// Run churn the population every interval until the soak duration is over.
+// Returns the first request which failed even after being retried
+func (s *Soak) Run(ctx context.Context) error {
+	start := time.Now()
+	end := start.Add(s.config.Duration)
+	for cycle := 0; ; cycle++ {
+		at := start.Add(time.Duration(cycle) * s.config.Interval)
+		if !at.Before(end) {
+			break
+		}
+		if !sleepContext(ctx, time.Until(at)) {
+			return ctx.Err()
+		}
+		if err := s.churn(ctx, cycle); err != nil {
+			return err
+		}
+	}
+	if !sleepContext(ctx, time.Until(end)) {
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+// Population get the CRs which exist now
+func (s *Soak) Population() []types.NamespacedName {
+	return append([]types.NamespacedName{}, s.population...)
+}
+
+// Created get every CR created by the soak, including those deleted since
+func (s *Soak) Created() []types.NamespacedName {
+	return append([]types.NamespacedName{}, s.created...)
+}
+
+// Deleted get every CR deleted by the soak, including those it created
+func (s *Soak) Deleted() []types.NamespacedName {
+	return append([]types.NamespacedName{}, s.deleted...)
+}
+
+// Cycles get the CRs churned in each interval
+func (s *Soak) Cycles() []SoakCycle {
+	return append([]SoakCycle{}, s.cycles...)
+}
+
+// churn delete the oldest CRs, create as many new ones and flip the size of as many of the remaining older CRs
+func (s *Soak) churn(ctx context.Context, cycle int) error {
+	_, offset := s.timeline.Current()
+	started := time.Now()
+	count := s.config.ChurnCount(len(s.population))
+	record := SoakCycle{Cycle: cycle, Offset: offset.Milliseconds()}
+
+	for _, key := range s.population[:count] {
+		if err := s.workload.Delete(ctx, key.Namespace, key.Name); err != nil {
+			return err
+		}
+		delete(s.sizes, key)
+		s.deleted = append(s.deleted, key)
+		record.Deleted = append(record.Deleted, key.Name)
+	}
+	s.population = s.population[count:]
+
+	// The new CRs are left out of the updates so they are not resized before the operator created them
+	older := len(s.population)
+	for i := 0; i < count; i++ {
+		key := s.cr(s.next)
+		s.next++
+		if err := s.workload.Create(ctx, NamedCR(s.sample, key.Name, key.Namespace)); err != nil {
+			return err
+		}
+		s.population = append(s.population, key)
+		s.sizes[key] = s.original
+		s.created = append(s.created, key)
+		record.Created = append(record.Created, key.Name)
+	}
+
+	resized := s.config.Sizes(s.original)
+	for i := 0; i < count && i < older && len(resized) > 0; i++ {
+		key := s.population[(cycle*count+i)%older]
+		size := resized[0]
+		if s.sizes[key] != s.original {
+			size = s.original
+		}
+		cr, err := WithSize(NamedCR(s.sample, key.Name, key.Namespace), s.oType, size)
+		if err != nil {
+			return err
+		}
+		if err := s.workload.Apply(ctx, cr); err != nil {
+			return err
+		}
+		s.sizes[key] = size
+		record.Updated = append(record.Updated, key.Name)
+	}
+
+	record.DurationMs = time.Since(started).Milliseconds()
+	s.cycles = append(s.cycles, record)
+
+	return nil
+}
+
+// FitMemoryTrend fit a least squares line through the memory points observed from the offset onwards, flagging a leak
+// when memory grows faster than maxSlope bytes per hour
+func FitMemoryTrend(series []UsagePoint, from int64, maxSlope float64) (MemoryTrend, error) {
+	trend := MemoryTrend{FromOffset: from, MaxSlopeBytesPerHour: maxSlope}
+
+	var xs, ys []float64
+	for _, point := range series {
+		if point.Offset < from {
+			continue
+		}
+		trend.Container = point.Container
+		xs = append(xs, float64(point.Offset-from)/float64(time.Hour.Milliseconds()))
+		ys = append(ys, float64(point.MemoryBytes))
+	}
+	trend.Points = len(xs)
+	if trend.Points < 2 {
+		return trend, fmt.Errorf("%d memory samples after the warmup, at least 2 are needed to fit a trend", trend.Points)
+	}
+
+	var err error
+	if trend.SlopeBytesPerHour, trend.InterceptBytes, trend.R2, err = fitLine(xs, ys); err != nil {
+		return trend, fmt.Errorf("memory samples after the warmup were all observed at the same time")
+	}
+	trend.Leaking = trend.SlopeBytesPerHour > maxSlope
+
+	return trend, nil
+}
+
+// fitLine fit a least squares line through the points, returning its slope, intercept and coefficient of
+// determination. Fails when every x is the same
+func fitLine(xs, ys []float64) (slope, intercept, r2 float64, err error) {
+	n := float64(len(xs))
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy, syy float64
+	for i := range xs {
+		sxx += (xs[i] - meanX) * (xs[i] - meanX)
+		sxy += (xs[i] - meanX) * (ys[i] - meanY)
+		syy += (ys[i] - meanY) * (ys[i] - meanY)
+	}
+	if sxx == 0 {
+		return 0, 0, 0, fmt.Errorf("cannot fit a line through points with the same x")
+	}
+
+	slope = sxy / sxx
+	intercept = meanY - slope*meanX
+	// A flat series is perfectly explained by a flat line
+	r2 = 1
+	if syy > 0 {
+		r2 = sxy * sxy / (sxx * syy)
+	}
+
+	return slope, intercept, r2, nil
+}
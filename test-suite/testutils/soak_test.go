@@ -0,0 +1,159 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Soak", func() {
+	It("should be disabled unless SOAK_DURATION is set", func() {
+		config, err := GetSoakConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.Enabled()).To(BeFalse())
+
+		Expect(os.Setenv("SOAK_DURATION", "4h")).To(Succeed())
+		defer os.Unsetenv("SOAK_DURATION")
+		config, err = GetSoakConfig()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config).To(Equal(SoakConfig{Duration: 4 * time.Hour, Warmup: DefaultSoakWarmup,
+			Interval: DefaultSoakInterval, Churn: DefaultSoakChurn, MaxSlope: DefaultSoakMaxSlope}))
+		Expect(config.Enabled()).To(BeTrue())
+
+		Expect(os.Setenv("SOAK_WARMUP", "5h")).To(Succeed())
+		defer os.Unsetenv("SOAK_WARMUP")
+		_, err = GetSoakConfig()
+		Expect(err).To(MatchError("SOAK_WARMUP must not be negative and must be shorter than SOAK_DURATION"))
+	})
+
+	It("should churn at least one and at most every CR", func() {
+		config := SoakConfig{Churn: 0.1}
+		Expect(config.ChurnCount(15)).To(Equal(2))
+		Expect(config.ChurnCount(5)).To(Equal(1))
+		config.Churn = 1
+		Expect(config.ChurnCount(15)).To(Equal(15))
+	})
+
+	It("should replace and resize a share of the CRs while keeping the population steady", func() {
+		ctx := context.TODO()
+		dynamicClient := newFakeDynamicClient()
+		reactToApply(dynamicClient)
+		timeline := NewTimeline()
+		driver := NewWorkloadDriver(dynamicClient, memcachedGVR, timeline, nil)
+		sample := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cache.example.com/v1alpha1",
+			"kind":       "Memcached",
+			"spec":       map[string]interface{}{"size": int64(1)},
+		}}
+		cr := func(i int) types.NamespacedName {
+			return types.NamespacedName{Namespace: Namespace, Name: fmt.Sprintf("memcached-sample%02d", i)}
+		}
+
+		population := make([]types.NamespacedName, 0, 4)
+		for i := 0; i < 4; i++ {
+			Expect(driver.Create(ctx, NamedCR(sample, cr(i).Name, Namespace))).To(Succeed())
+			population = append(population, cr(i))
+		}
+
+		config := SoakConfig{Duration: 250 * time.Millisecond, Interval: 100 * time.Millisecond, Churn: 0.5}
+		soak, err := NewSoak(config, driver, timeline, sample, GoType, population, cr)
+		Expect(err).NotTo(HaveOccurred())
+		started := time.Now()
+		Expect(soak.Run(ctx)).To(Succeed())
+		Expect(time.Since(started)).To(BeNumerically(">=", config.Duration))
+
+		cycles := soak.Cycles()
+		Expect(cycles).To(HaveLen(3))
+		Expect(cycles[0].Deleted).To(Equal([]string{"memcached-sample00", "memcached-sample01"}))
+		Expect(cycles[0].Created).To(Equal([]string{"memcached-sample04", "memcached-sample05"}))
+		Expect(cycles[0].Updated).To(Equal([]string{"memcached-sample02", "memcached-sample03"}))
+		Expect(cycles[1].Deleted).To(Equal([]string{"memcached-sample02", "memcached-sample03"}))
+		Expect(soak.Created()).To(HaveLen(6))
+		Expect(soak.Deleted()).To(Equal([]types.NamespacedName{cr(0), cr(1), cr(2), cr(3), cr(4), cr(5)}))
+		Expect(soak.Population()).To(Equal([]types.NamespacedName{cr(6), cr(7), cr(8), cr(9)}))
+
+		crs, err := dynamicClient.Resource(memcachedGVR).Namespace(Namespace).List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(crs.Items).To(HaveLen(4))
+		sizes := map[string]int64{}
+		for _, cr := range crs.Items {
+			sizes[cr.GetName()], err = SizeOf(&cr, GoType)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		// The CRs created in the second cycle were resized by the third
+		Expect(sizes).To(Equal(map[string]int64{"memcached-sample06": 2, "memcached-sample07": 2,
+			"memcached-sample08": 1, "memcached-sample09": 1}))
+	})
+
+	It("should not resize CRs of size 0, so no operand pods are run", func() {
+		config := SoakConfig{Duration: 250 * time.Millisecond, Interval: 100 * time.Millisecond, Churn: 0.5}
+		Expect(config.Sizes(1)).To(Equal([]int64{2}))
+		Expect(config.Sizes(0)).To(BeEmpty())
+		Expect(SoakConfig{}.Sizes(1)).To(BeEmpty())
+
+		ctx := context.TODO()
+		dynamicClient := newFakeDynamicClient()
+		reactToApply(dynamicClient)
+		driver := NewWorkloadDriver(dynamicClient, memcachedGVR, NewTimeline(), nil)
+		sample := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cache.example.com/v1alpha1",
+			"kind":       "Memcached",
+			"spec":       map[string]interface{}{"size": int64(0)},
+		}}
+		cr := func(i int) types.NamespacedName {
+			return types.NamespacedName{Namespace: Namespace, Name: fmt.Sprintf("memcached-sample%02d", i)}
+		}
+		population := []types.NamespacedName{cr(0), cr(1)}
+		for _, key := range population {
+			Expect(driver.Create(ctx, NamedCR(sample, key.Name, Namespace))).To(Succeed())
+		}
+
+		soak, err := NewSoak(config, driver, NewTimeline(), sample, GoType, population, cr)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(soak.Run(ctx)).To(Succeed())
+		for _, cycle := range soak.Cycles() {
+			Expect(cycle.Created).NotTo(BeEmpty())
+			Expect(cycle.Updated).To(BeEmpty())
+		}
+		crs, err := dynamicClient.Resource(memcachedGVR).Namespace(Namespace).List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		for _, cr := range crs.Items {
+			Expect(SizeOf(&cr, GoType)).To(BeZero())
+		}
+	})
+
+	It("should fit the memory growth after the warmup", func() {
+		hour := time.Hour.Milliseconds()
+		series := []UsagePoint{
+			// Memory grows quickly while caches fill up during the warmup
+			{Container: ManagerContainerName, Offset: 0, MemoryBytes: 10_000_000},
+			{Container: ManagerContainerName, Offset: hour / 2, MemoryBytes: 40_000_000},
+			{Container: ManagerContainerName, Offset: hour, MemoryBytes: 50_000_000},
+			{Container: ManagerContainerName, Offset: 2 * hour, MemoryBytes: 51_000_000},
+			{Container: ManagerContainerName, Offset: 3 * hour, MemoryBytes: 52_000_000},
+		}
+
+		trend, err := FitMemoryTrend(series, hour, 2_000_000)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(trend.Container).To(Equal(ManagerContainerName))
+		Expect(trend.Points).To(Equal(3))
+		Expect(trend.SlopeBytesPerHour).To(BeNumerically("~", 1_000_000, 1))
+		Expect(trend.InterceptBytes).To(BeNumerically("~", 50_000_000, 1))
+		Expect(trend.R2).To(BeNumerically("~", 1, 1e-9))
+		Expect(trend.Leaking).To(BeFalse())
+
+		trend, err = FitMemoryTrend(series, hour, 500_000)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(trend.Leaking).To(BeTrue())
+
+		_, err = FitMemoryTrend(series, 3*hour, 500_000)
+		Expect(err).To(MatchError("1 memory samples after the warmup, at least 2 are needed to fit a trend"))
+	})
+})
@@ -0,0 +1,158 @@
+package testutils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// StreamIndex The final record of a stream. A stream without one is from a run that was killed before it finished
+type StreamIndex struct {
+	RunID string `json:"runId,omitempty"`
+	// Complete is false when the run failed before the stream was finished
+	Complete bool              `json:"complete"`
+	Records  int               `json:"records"`
+	Started  time.Time         `json:"started"`
+	Finished time.Time         `json:"finished"`
+	Phases   []PhaseTransition `json:"phases,omitempty"`
+}
+
+// streamIndexRecord wraps the index so it can be told apart from the records of the stream
+type streamIndexRecord struct {
+	Index *StreamIndex `json:"index"`
+}
+
+// StreamWriter Append-only NDJSON file each record is written to as soon as it is gathered,
+// so the records of a run are kept even if the run fails or is killed partway through
+type StreamWriter struct {
+	mu      sync.Mutex
+	f       *os.File
+	enc     *json.Encoder
+	records int
+	started time.Time
+	closed  bool
+}
+
+// NewStreamWriter create a new NDJSON file in the directory of the results directory
+func NewStreamWriter(dir string) (*StreamWriter, error) {
+	path, err := MakeResultsDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(fmt.Sprintf("%s/%d.ndjson", path, time.Now().UnixMicro()), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamWriter{
+		f:       f,
+		enc:     json.NewEncoder(f),
+		started: time.Now(),
+	}, nil
+}
+
+// Name get the path of the file written to
+func (w *StreamWriter) Name() string {
+	return w.f.Name()
+}
+
+// Write append the record to the stream as a single line
+func (w *StreamWriter) Write(record interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return fmt.Errorf("stream %s is closed", w.f.Name())
+	}
+	if err := w.enc.Encode(record); err != nil {
+		return err
+	}
+	w.records++
+
+	return nil
+}
+
+// Sync flush the stream to disk
+func (w *StreamWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+
+	return w.f.Sync()
+}
+
+// Finish write the index record, including the run and phases of the timeline if set, and close the stream.
+// Finishing an already closed stream does nothing
+func (w *StreamWriter) Finish(complete bool, timeline *Timeline) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	index := &StreamIndex{
+		Complete: complete,
+		Records:  w.records,
+		Started:  w.started,
+		Finished: time.Now(),
+	}
+	if timeline != nil {
+		index.RunID = timeline.RunID()
+		index.Phases = timeline.Transitions()
+	}
+
+	err := w.enc.Encode(streamIndexRecord{Index: index})
+	if err == nil {
+		err = w.f.Sync()
+	}
+	if closeErr := w.f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// LoadStream read the records of an NDJSON stream. The returned index is nil if the stream was never finished
+func LoadStream(path string) ([]json.RawMessage, *StreamIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var (
+		records []json.RawMessage
+		index   *StreamIndex
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		indexRecord := streamIndexRecord{}
+		if err := json.Unmarshal(line, &indexRecord); err == nil && indexRecord.Index != nil {
+			index = indexRecord.Index
+			continue
+		}
+
+		// A run killed mid-write can leave a truncated last line, which is skipped
+		if !json.Valid(line) {
+			continue
+		}
+		records = append(records, append(json.RawMessage{}, line...))
+	}
+
+	return records, index, scanner.Err()
+}
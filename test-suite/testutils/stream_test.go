@@ -0,0 +1,60 @@
+package testutils
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StreamWriter", func() {
+	var resultsDir string
+
+	BeforeEach(func() {
+		var err error
+		resultsDir, err = os.MkdirTemp("", "results")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Setenv("RESULTS_DIR", resultsDir)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.Unsetenv("RESULTS_DIR")).To(Succeed())
+		Expect(os.RemoveAll(resultsDir)).To(Succeed())
+	})
+
+	It("should load the records of a stream killed before it was finished", func() {
+		writer, err := NewStreamWriter("run/cpuMemory")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Write(map[string]int{"offset": 1000})).To(Succeed())
+		Expect(writer.Sync()).To(Succeed())
+
+		// Simulate a process killed partway through writing a record
+		f, err := os.OpenFile(writer.Name(), os.O_APPEND|os.O_WRONLY, 0644)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = f.WriteString(`{"offset": 20`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+
+		records, index, err := LoadStream(writer.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		Expect(string(records[0])).To(MatchJSON(`{"offset": 1000}`))
+		Expect(index).To(BeNil())
+	})
+
+	It("should mark a stream finished after a failure as incomplete", func() {
+		writer, err := NewStreamWriter("run/cpuMemory")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer.Write(map[string]int{"offset": 1000})).To(Succeed())
+		Expect(writer.Finish(false, nil)).To(Succeed())
+		Expect(writer.Finish(true, nil)).To(Succeed())
+		Expect(writer.Write(map[string]int{"offset": 2000})).NotTo(Succeed())
+
+		records, index, err := LoadStream(writer.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(1))
+		Expect(index).NotTo(BeNil())
+		Expect(index.Complete).To(BeFalse())
+		Expect(index.Records).To(Equal(1))
+	})
+})
@@ -0,0 +1,76 @@
+package testutils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	RoleOperator  = "operator"
+	RoleOperand   = "operand"
+	RoleAPIServer = "kube-apiserver"
+	RoleEtcd      = "etcd"
+
+	ControlPlaneNamespace = "kube-system"
+)
+
+// SampleTarget A group of pods to gather metrics for, tagged with the role they play in the run
+type SampleTarget struct {
+	Role          string `json:"role"`
+	Namespace     string `json:"namespace"`
+	LabelSelector string `json:"labelSelector"`
+}
+
+// OperandPodLabel get the label selector of the memcached pods created by the operator type
+func OperandPodLabel(oType string) string {
+	// Helm has different labels
+	if oType == HelmType {
+		return "app.kubernetes.io/name=memcached"
+	}
+
+	return "app=memcached"
+}
+
+// GetSampleTargets get the pods to gather metrics for. The operator pod is always sampled, other targets default to
+// the memcached operands, kube-apiserver and etcd and can be replaced using the SAMPLE_TARGETS env variable in the
+// format role:namespace:selector separated by semicolons. The operands are sampled in operandNamespace, all namespaces
+// if it is empty
+func GetSampleTargets(oType, operandNamespace string) ([]SampleTarget, error) {
+	targets := []SampleTarget{{Role: RoleOperator, Namespace: Namespace, LabelSelector: OperatorPodLabel}}
+
+	sampleTargets := os.Getenv("SAMPLE_TARGETS")
+	if sampleTargets == "" {
+		return append(targets,
+			SampleTarget{Role: RoleOperand, Namespace: operandNamespace, LabelSelector: OperandPodLabel(oType)},
+			SampleTarget{Role: RoleAPIServer, Namespace: ControlPlaneNamespace, LabelSelector: "component=kube-apiserver"},
+			SampleTarget{Role: RoleEtcd, Namespace: ControlPlaneNamespace, LabelSelector: "component=etcd"},
+		), nil
+	}
+
+	for _, target := range strings.Split(sampleTargets, ";") {
+		if strings.TrimSpace(target) == "" {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSpace(target), ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid sample target %q, expected role:namespace:selector", target)
+		}
+		if parts[0] == RoleOperator {
+			return nil, fmt.Errorf("sample target role %q is reserved for the operator pod", RoleOperator)
+		}
+		targets = append(targets, SampleTarget{Role: parts[0], Namespace: parts[1], LabelSelector: parts[2]})
+	}
+
+	return targets, nil
+}
+
+// SeriesDir get the results directory name for the pod metrics of a role.
+// The operator keeps the cpuMemory directory so existing analysis continues to work
+func (t SampleTarget) SeriesDir() string {
+	if t.Role == RoleOperator {
+		return "cpuMemory"
+	}
+
+	return fmt.Sprintf("cpuMemory-%s", t.Role)
+}
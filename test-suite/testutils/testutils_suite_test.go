@@ -0,0 +1,14 @@
+package testutils
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// TestTestutils runs the unit tests of the test suite utilities, which do not need a cluster
+func TestTestutils(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Testutils Suite")
+}
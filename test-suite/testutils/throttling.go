@@ -0,0 +1,234 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CFSPeriodsMetric          = "container_cpu_cfs_periods_total"
+	CFSThrottledPeriodsMetric = "container_cpu_cfs_throttled_periods_total"
+	CFSThrottledSecondsMetric = "container_cpu_cfs_throttled_seconds_total"
+	ManagerContainerName      = "manager"
+	// throttlingInterval is how often the cAdvisor counters are read, cAdvisor itself only updates every few seconds
+	throttlingInterval = 5 * time.Second
+)
+
+// ThrottlingSample CFS counters of a container tagged with the phase of the run they were read in
+type ThrottlingSample struct {
+	Phase Phase `json:"phase"`
+	// Offset is the number of milliseconds since the start of the run
+	Offset           int64     `json:"offset"`
+	ObservedAt       time.Time `json:"observedAt"`
+	Periods          float64   `json:"periods"`
+	ThrottledPeriods float64   `json:"throttledPeriods"`
+	ThrottledSeconds float64   `json:"throttledSeconds"`
+}
+
+// PhaseThrottling How many of the CFS periods a container ran in during a phase it was throttled in
+type PhaseThrottling struct {
+	Phase            Phase   `json:"phase"`
+	Periods          float64 `json:"periods"`
+	ThrottledPeriods float64 `json:"throttledPeriods"`
+	ThrottledSeconds float64 `json:"throttledSeconds"`
+	// Ratio is the share of periods the container was throttled in
+	Ratio float64 `json:"ratio"`
+}
+
+// ThrottlingCollector Read the CFS throttling counters of a container from the kubelet cAdvisor endpoint through
+// the API server node proxy over the whole run
+type ThrottlingCollector struct {
+	httpClient *http.Client
+	host       string
+	timeline   *Timeline
+	namespace  string
+	pod        string
+	container  string
+
+	mu       sync.Mutex
+	node     string
+	writer   *StreamWriter
+	writeErr error
+	last     *ThrottlingSample
+	phases   []*PhaseThrottling
+
+	loop *backgroundLoop
+}
+
+// NewThrottlingCollector create a collector for a container of a pod, using an HTTP client authenticated against
+// the API server at host
+func NewThrottlingCollector(httpClient *http.Client, host string, timeline *Timeline, namespace, pod, container string) *ThrottlingCollector {
+	return &ThrottlingCollector{
+		httpClient: httpClient,
+		host:       strings.TrimSuffix(host, "/"),
+		timeline:   timeline,
+		namespace:  namespace,
+		pod:        pod,
+		container:  container,
+	}
+}
+
+// Start reading the counters in the background, streaming them to cpuThrottling in the results directory dir
+func (t *ThrottlingCollector) Start(ctx context.Context, dir string) error {
+	writer, err := NewStreamWriter(fmt.Sprintf("%s/cpuThrottling", dir))
+	if err != nil {
+		return err
+	}
+	t.writer = writer
+	t.loop = startLoop(ctx, throttlingInterval, t.collect)
+
+	return nil
+}
+
+// Stop reading the counters and mark the stream complete
+func (t *ThrottlingCollector) Stop() error {
+	return t.finish(true)
+}
+
+// Close stop reading the counters if Stop was not called and mark the stream incomplete
+func (t *ThrottlingCollector) Close() error {
+	return t.finish(false)
+}
+
+// Summary get the throttling ratio of each phase in the order the phases happened
+func (t *ThrottlingCollector) Summary() []PhaseThrottling {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	summary := make([]PhaseThrottling, 0, len(t.phases))
+	for _, phase := range t.phases {
+		phaseThrottling := *phase
+		if phaseThrottling.Periods > 0 {
+			phaseThrottling.Ratio = phaseThrottling.ThrottledPeriods / phaseThrottling.Periods
+		}
+		summary = append(summary, phaseThrottling)
+	}
+
+	return summary
+}
+
+// collect read the counters once and add the increase since the last read to the current phase
+func (t *ThrottlingCollector) collect(ctx context.Context) {
+	sample, err := t.read(ctx)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.writer != nil {
+		if err := t.writer.Write(sample); err != nil && t.writeErr == nil {
+			t.writeErr = err
+		}
+	}
+
+	if t.last != nil {
+		periods := sample.Periods - t.last.Periods
+		throttledPeriods := sample.ThrottledPeriods - t.last.ThrottledPeriods
+		throttledSeconds := sample.ThrottledSeconds - t.last.ThrottledSeconds
+		// The counters reset when the container restarts
+		if periods < 0 || throttledPeriods < 0 || throttledSeconds < 0 {
+			periods, throttledPeriods, throttledSeconds = sample.Periods, sample.ThrottledPeriods, sample.ThrottledSeconds
+		}
+
+		phase := t.phase(sample.Phase)
+		phase.Periods += periods
+		phase.ThrottledPeriods += throttledPeriods
+		phase.ThrottledSeconds += throttledSeconds
+	}
+	t.last = sample
+}
+
+// phase get the throttling of a phase, must be called with the lock held
+func (t *ThrottlingCollector) phase(phase Phase) *PhaseThrottling {
+	for _, p := range t.phases {
+		if p.Phase == phase {
+			return p
+		}
+	}
+	t.phases = append(t.phases, &PhaseThrottling{Phase: phase})
+
+	return t.phases[len(t.phases)-1]
+}
+
+// read the CFS counters of the container from the cAdvisor metrics of the node the pod runs on
+func (t *ThrottlingCollector) read(ctx context.Context) (*ThrottlingSample, error) {
+	if t.node == "" {
+		pod := corev1.Pod{}
+		if err := getJSON(ctx, t.httpClient, fmt.Sprintf("%s/api/v1/namespaces/%s/pods/%s", t.host, t.namespace, t.pod), &pod); err != nil {
+			return nil, err
+		}
+		if pod.Spec.NodeName == "" {
+			return nil, fmt.Errorf("pod %s/%s is not scheduled", t.namespace, t.pod)
+		}
+		t.node = pod.Spec.NodeName
+	}
+
+	families, err := ScrapeMetrics(ctx, t.httpClient, fmt.Sprintf("%s/api/v1/nodes/%s/proxy/metrics/cadvisor", t.host, t.node))
+	if err != nil {
+		return nil, err
+	}
+
+	phase, offset := t.timeline.Current()
+	sample := &ThrottlingSample{
+		Phase:      phase,
+		Offset:     offset.Milliseconds(),
+		ObservedAt: time.Now(),
+	}
+	found := false
+	for name, value := range map[string]*float64{
+		CFSPeriodsMetric:          &sample.Periods,
+		CFSThrottledPeriodsMetric: &sample.ThrottledPeriods,
+		CFSThrottledSecondsMetric: &sample.ThrottledSeconds,
+	} {
+		if v, ok := t.containerCounter(families[name]); ok {
+			*value = v
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no CFS metrics for container %s of pod %s/%s, does it have a cpu limit?", t.container, t.namespace, t.pod)
+	}
+
+	return sample, nil
+}
+
+// containerCounter get the value of the counter for the container
+func (t *ThrottlingCollector) containerCounter(family *dto.MetricFamily) (float64, bool) {
+	for _, metric := range family.GetMetric() {
+		labels := map[string]string{}
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		if labels["namespace"] == t.namespace && labels["pod"] == t.pod && labels["container"] == t.container {
+			return metric.GetCounter().GetValue(), true
+		}
+	}
+
+	return 0, false
+}
+
+// finish stop reading the counters and write the index of the stream
+func (t *ThrottlingCollector) finish(complete bool) error {
+	t.loop.stop()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.writer == nil {
+		return nil
+	}
+	err := t.writeErr
+	if finishErr := t.writer.Finish(complete, t.timeline); err == nil {
+		err = finishErr
+	}
+
+	return err
+}
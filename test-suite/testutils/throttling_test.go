@@ -0,0 +1,62 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const fakeCadvisorMetrics = `# HELP container_cpu_cfs_periods_total Number of elapsed enforcement period intervals.
+# TYPE container_cpu_cfs_periods_total counter
+container_cpu_cfs_periods_total{container="manager",namespace="memcached-operator-system",pod="manager-pod"} %d
+container_cpu_cfs_periods_total{container="kube-rbac-proxy",namespace="memcached-operator-system",pod="manager-pod"} 999
+# HELP container_cpu_cfs_throttled_periods_total Number of throttled period intervals.
+# TYPE container_cpu_cfs_throttled_periods_total counter
+container_cpu_cfs_throttled_periods_total{container="manager",namespace="memcached-operator-system",pod="manager-pod"} %d
+# HELP container_cpu_cfs_throttled_seconds_total Total time duration the container has been throttled.
+# TYPE container_cpu_cfs_throttled_seconds_total counter
+container_cpu_cfs_throttled_seconds_total{container="manager",namespace="memcached-operator-system",pod="manager-pod"} %d
+`
+
+var _ = Describe("ThrottlingCollector", func() {
+	It("should report the throttling ratio of each phase", func() {
+		counters := [][3]int{{100, 10, 1}, {200, 20, 2}, {400, 120, 12}, {50, 50, 5}}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v1/namespaces/memcached-operator-system/pods/manager-pod":
+				_, _ = w.Write([]byte(`{"metadata":{"name":"manager-pod"},"spec":{"nodeName":"kind-control-plane"}}`))
+			case "/api/v1/nodes/kind-control-plane/proxy/metrics/cadvisor":
+				c := counters[0]
+				counters = counters[1:]
+				_, _ = fmt.Fprintf(w, fakeCadvisorMetrics, c[0], c[1], c[2])
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		timeline := NewTimeline()
+		collector := NewThrottlingCollector(server.Client(), server.URL, timeline, Namespace, "manager-pod", ManagerContainerName)
+
+		collector.collect(context.TODO())
+		collector.collect(context.TODO())
+		timeline.Mark(PhaseCreate)
+		collector.collect(context.TODO())
+		// The container restarted and its counters were reset
+		collector.collect(context.TODO())
+		Expect(collector.Stop()).To(Succeed())
+
+		summary := collector.Summary()
+		Expect(summary).To(HaveLen(2))
+		Expect(summary[0]).To(Equal(PhaseThrottling{
+			Phase: PhaseBaseline, Periods: 100, ThrottledPeriods: 10, ThrottledSeconds: 1, Ratio: 0.1,
+		}))
+		Expect(summary[1]).To(Equal(PhaseThrottling{
+			Phase: PhaseCreate, Periods: 250, ThrottledPeriods: 150, ThrottledSeconds: 15, Ratio: 0.6,
+		}))
+	})
+})
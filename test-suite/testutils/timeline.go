@@ -0,0 +1,106 @@
+package testutils
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Phase identifies the stage of a performance run
+type Phase string
+
+const (
+	PhaseBaseline Phase = "baseline"
+	PhaseCreate   Phase = "create"
+	PhaseSteady   Phase = "steady"
+	PhaseUpdate   Phase = "update"
+	PhaseSoak     Phase = "soak"
+	PhaseDelete   Phase = "delete"
+	PhaseCooldown Phase = "cooldown"
+	PhaseCoalesce Phase = "coalesce"
+)
+
+// PhaseTransition records the point in a run where a phase started
+type PhaseTransition struct {
+	Phase Phase     `json:"phase"`
+	Time  time.Time `json:"time"`
+	// Offset is the number of milliseconds since the start of the run
+	Offset int64 `json:"offset"`
+}
+
+// Timeline tracks the current phase of a run and when each phase started.
+// Offsets are taken from the monotonic clock so wall clock changes during a run do not affect them.
+type Timeline struct {
+	mu          sync.RWMutex
+	start       time.Time
+	phase       Phase
+	transitions []PhaseTransition
+}
+
+// NewTimeline start a new run timeline in the baseline phase
+func NewTimeline() *Timeline {
+	start := time.Now()
+	return &Timeline{
+		start:       start,
+		phase:       PhaseBaseline,
+		transitions: []PhaseTransition{{Phase: PhaseBaseline, Time: start}},
+	}
+}
+
+// Start return the time the run started
+func (t *Timeline) Start() time.Time {
+	return t.start
+}
+
+// RunID return the identifier of the run, used to link the metadata of a run to the series saved during it
+func (t *Timeline) RunID() string {
+	return strconv.FormatInt(t.start.UnixMicro(), 10)
+}
+
+// Mark transition the run to a new phase
+func (t *Timeline) Mark(phase Phase) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.phase = phase
+	t.transitions = append(t.transitions, PhaseTransition{
+		Phase:  phase,
+		Time:   now,
+		Offset: now.Sub(t.start).Milliseconds(),
+	})
+}
+
+// Current return the current phase and the offset from the start of the run
+func (t *Timeline) Current() (Phase, time.Duration) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.phase, time.Since(t.start)
+}
+
+// Transitions return a copy of all phase transitions in the order they happened
+func (t *Timeline) Transitions() []PhaseTransition {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	transitions := make([]PhaseTransition, len(t.transitions))
+	copy(transitions, t.transitions)
+	return transitions
+}
+
+// PhaseAt return the phase the run was in at a wall clock time, times before the run are in the baseline phase
+func (t *Timeline) PhaseAt(at time.Time) Phase {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	phase := PhaseBaseline
+	for _, transition := range t.transitions {
+		if at.Before(transition.Time) {
+			break
+		}
+		phase = transition.Phase
+	}
+
+	return phase
+}
@@ -18,10 +18,15 @@
 package testutils
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -163,6 +168,95 @@ func (tc TestContext) PatchDeployment(deploymentName, nameSpace, jsonPatch strin
 	return err
 }
 
+// PortForward A kubectl port-forward running in the background
+type PortForward struct {
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	// LocalPort is the port on localhost forwarded to the pod
+	LocalPort int
+}
+
+var (
+	portForwardPattern = regexp.MustCompile(`Forwarding from 127\.0\.0\.1:(\d+)`)
+	// portForwardTimeout is how long kubectl has to report the local port before it is killed
+	portForwardTimeout = 30 * time.Second
+)
+
+// PortForwardPod Forward a random local port to a port of a pod until Close is called.
+// Ports the pod only binds on localhost, such as the manager metrics port, can be reached this way
+func (tc TestContext) PortForwardPod(podName, nameSpace string, remotePort int) (*PortForward, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, "kubectl", "port-forward", "-n", nameSpace, fmt.Sprintf("pod/%s", podName),
+		fmt.Sprintf(":%d", remotePort))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Wait for kubectl to report the local port it is listening on, killing it if it does not in time so the scan
+	// ends rather than blocking forever
+	timer := time.AfterFunc(portForwardTimeout, cancel)
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		match := portForwardPattern.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		localPort, err := strconv.Atoi(match[1])
+		if err != nil || !timer.Stop() {
+			break
+		}
+
+		// Keep draining the output so kubectl never blocks on a full pipe
+		go func() {
+			for scanner.Scan() {
+			}
+		}()
+		return &PortForward{cmd: cmd, cancel: cancel, LocalPort: localPort}, nil
+	}
+
+	timedOut := !timer.Stop()
+	cancel()
+	_ = cmd.Wait()
+	if timedOut {
+		return nil, fmt.Errorf("port-forward to pod %s/%s:%d did not report its local port within %v", nameSpace,
+			podName, remotePort, portForwardTimeout)
+	}
+	return nil, fmt.Errorf("port-forward to pod %s/%s:%d failed", nameSpace, podName, remotePort)
+}
+
+// Close stop the port-forward
+func (p *PortForward) Close() error {
+	p.cancel()
+	_ = p.cmd.Wait()
+
+	return nil
+}
+
+// URL get the URL of a path on the forwarded port
+func (p *PortForward) URL(path string) string {
+	return fmt.Sprintf("http://127.0.0.1:%d%s", p.LocalPort, path)
+}
+
+// ExecPod Run a command in a container of a pod and get its stdout, the command is killed when the context is done
+func (tc TestContext) ExecPod(ctx context.Context, podName, nameSpace, container string, command ...string) (string, error) {
+	args := append([]string{"exec", "-n", nameSpace, podName, "-c", container, "--"}, command...)
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("exec in pod %s/%s failed: %v: %s", nameSpace, podName, err, stderr.String())
+	}
+
+	return string(stdout), nil
+}
+
 // InstallKubeStateMetrics Install Kube-state-metrics
 func (tc TestContext) InstallKubeStateMetrics() error {
 	_, err := tc.Kubectl.Apply(false, "-f", fmt.Sprintf("https://raw.githubusercontent.com/kubernetes/kube-state-metrics/%s/examples/standard/cluster-role-binding.yaml", KubeStateMetricsVersion))
@@ -187,9 +281,34 @@ func (tc TestContext) InstallKubeStateMetrics() error {
 	return err
 }
 
-// CreateKindCluster Create local kind cluster
-func (tc TestContext) CreateKindCluster() error {
-	return exec.Command("kind", "create", "cluster").Run()
+// CreateKindCluster Create local kind cluster. When auditUser is set the API server writes an audit log of the
+// requests made by that user, and when maxPods is set the node runs up to maxPods pods rather than the kubelet's 110
+func (tc TestContext) CreateKindCluster(auditUser string, maxPods int) error {
+	args := []string{"create", "cluster"}
+	if auditUser != "" || maxPods > 0 {
+		config, err := WriteKindConfig(auditUser, maxPods)
+		if err != nil {
+			return err
+		}
+		args = append(args, "--config", config)
+	}
+
+	return exec.Command("kind", args...).Run()
+}
+
+// GetAuditLog get the API server audit log from the control plane node of the kind cluster
+func (tc TestContext) GetAuditLog() (string, error) {
+	cluster := "kind"
+	if v, ok := os.LookupEnv("KIND_CLUSTER"); ok {
+		cluster = v
+	}
+
+	out, err := exec.Command("docker", "exec", fmt.Sprintf("%s-control-plane", cluster), "cat", AuditLogPath).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the audit log of kind cluster %s: %v", cluster, err)
+	}
+
+	return string(out), nil
 }
 
 // DeleteKindCluster delete local kind cluster
@@ -0,0 +1,49 @@
+package testutils
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeKubectl put a kubectl running script first on the PATH, returning a func restoring the PATH
+func fakeKubectl(script string) func() {
+	dir, err := os.MkdirTemp("", "kubectl")
+	Expect(err).NotTo(HaveOccurred())
+	Expect(os.WriteFile(filepath.Join(dir, "kubectl"), []byte("#!/bin/sh\n"+script+"\n"), 0755)).To(Succeed())
+
+	path := os.Getenv("PATH")
+	Expect(os.Setenv("PATH", dir+string(os.PathListSeparator)+path)).To(Succeed())
+	return func() {
+		Expect(os.Setenv("PATH", path)).To(Succeed())
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	}
+}
+
+var _ = Describe("PortForwardPod", func() {
+	It("should get the local port kubectl reports", func() {
+		defer fakeKubectl("echo 'Forwarding from 127.0.0.1:41234 -> 8080'; exec sleep 60")()
+
+		portForward, err := TestContext{}.PortForwardPod("manager", Namespace, ManagerMetricsPort)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(portForward.URL("/metrics")).To(Equal("http://127.0.0.1:41234/metrics"))
+		Expect(portForward.Close()).To(Succeed())
+	})
+
+	It("should kill kubectl when it does not report the local port in time", func() {
+		defer fakeKubectl("exec sleep 60")()
+		timeout := portForwardTimeout
+		portForwardTimeout = 100 * time.Millisecond
+		defer func() {
+			portForwardTimeout = timeout
+		}()
+
+		start := time.Now()
+		_, err := TestContext{}.PortForwardPod("manager", Namespace, ManagerMetricsPort)
+		Expect(err).To(MatchError(ContainSubstring("did not report its local port within 100ms")))
+		Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+	})
+})
@@ -0,0 +1,230 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// WorkloadFieldManager owns the fields of the CRs the workload driver applies
+	WorkloadFieldManager = "osdk-perf-workload"
+	// WorkloadRetryTimeout is how long a transient failure is retried for, such as while the operator's webhooks are starting
+	WorkloadRetryTimeout  = time.Minute
+	WorkloadRetryInterval = time.Second
+
+	WorkloadApply  = "apply"
+	WorkloadDelete = "delete"
+)
+
+// WorkloadRequest A request the workload driver made to the API server
+type WorkloadRequest struct {
+	Verb      string `json:"verb"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Phase     Phase  `json:"phase"`
+	// Offset is the number of milliseconds since the start of the run the request was first sent at
+	Offset int64     `json:"offset"`
+	SentAt time.Time `json:"sentAt"`
+	// LatencyMs is how long the last attempt took to be answered, Attempts is more than 1 if earlier ones failed
+	LatencyMs float64 `json:"latencyMs"`
+	Attempts  int     `json:"attempts"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// WorkloadDriver Create, update and delete CRs through the dynamic client using server-side apply, recording when
+// each request was sent and how long it took. Requests are passed to the lifecycle tracker if there is one
+type WorkloadDriver struct {
+	dynamicClient dynamic.Interface
+	gvr           schema.GroupVersionResource
+	timeline      *Timeline
+	tracker       *LifecycleTracker
+
+	mu       sync.Mutex
+	requests []WorkloadRequest
+}
+
+// NewWorkloadDriver create a driver for the CRs of gvr, tracker can be nil
+func NewWorkloadDriver(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, timeline *Timeline,
+	tracker *LifecycleTracker) *WorkloadDriver {
+	return &WorkloadDriver{
+		dynamicClient: dynamicClient,
+		gvr:           gvr,
+		timeline:      timeline,
+		tracker:       tracker,
+	}
+}
+
+// LoadSample read a CR from a YAML or JSON file
+func LoadSample(file string) (*unstructured.Unstructured, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	data, err = yaml.ToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sample %s: %v", file, err)
+	}
+
+	// Decoding as unstructured keeps integers such as spec.size as int64 rather than float64
+	sample := &unstructured.Unstructured{}
+	if err := sample.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("failed to decode sample %s: %v", file, err)
+	}
+
+	return sample, nil
+}
+
+// NamedCR copy the sample CR with the name and namespace
+func NamedCR(sample *unstructured.Unstructured, name, namespace string) *unstructured.Unstructured {
+	cr := sample.DeepCopy()
+	cr.SetName(name)
+	cr.SetNamespace(namespace)
+
+	return cr
+}
+
+// Create the CR, recording it as requested in the lifecycle tracker. The request is dropped again if the CR could
+// not be created
+func (w *WorkloadDriver) Create(ctx context.Context, cr *unstructured.Unstructured) error {
+	key := types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}
+	if w.tracker != nil {
+		w.tracker.Requested(key, time.Now())
+	}
+
+	err := w.Apply(ctx, cr)
+	if err != nil && w.tracker != nil {
+		w.tracker.CreateFailed(key)
+	}
+
+	return err
+}
+
+// Apply the fields set in the CR with server-side apply, creating it if it does not exist
+func (w *WorkloadDriver) Apply(ctx context.Context, cr *unstructured.Unstructured) error {
+	data, err := cr.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	force := true
+	return w.do(ctx, WorkloadApply, cr.GetNamespace(), cr.GetName(), func(ctx context.Context) error {
+		_, err := w.dynamicClient.Resource(w.gvr).Namespace(cr.GetNamespace()).Patch(ctx, cr.GetName(),
+			types.ApplyPatchType, data, metav1.PatchOptions{FieldManager: WorkloadFieldManager, Force: &force})
+		return err
+	})
+}
+
+// Delete the CR, recording the deletion as requested in the lifecycle tracker
+func (w *WorkloadDriver) Delete(ctx context.Context, namespace, name string) error {
+	if w.tracker != nil {
+		w.tracker.DeleteRequested(types.NamespacedName{Namespace: namespace, Name: name}, time.Now())
+	}
+
+	return w.do(ctx, WorkloadDelete, namespace, name, func(ctx context.Context) error {
+		return w.dynamicClient.Resource(w.gvr).Namespace(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	})
+}
+
+// Requests get the requests made in the order they were sent
+func (w *WorkloadDriver) Requests() []WorkloadRequest {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	requests := make([]WorkloadRequest, len(w.requests))
+	copy(requests, w.requests)
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].SentAt.Before(requests[j].SentAt)
+	})
+
+	return requests
+}
+
+// Latencies get the percentiles of the latency of the successful requests of each verb
+func (w *WorkloadDriver) Latencies() map[string]LatencyPercentiles {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	latencies := map[string][]float64{}
+	for _, request := range w.requests {
+		if request.Error == "" {
+			latencies[request.Verb] = append(latencies[request.Verb], request.LatencyMs)
+		}
+	}
+
+	return latencyPercentiles(latencies)
+}
+
+// do send a request, retrying transient failures until it succeeds or WorkloadRetryTimeout passes, and record it
+func (w *WorkloadDriver) do(ctx context.Context, verb, namespace, name string, send func(ctx context.Context) error) error {
+	phase, offset := w.timeline.Current()
+	request := WorkloadRequest{
+		Verb:      verb,
+		Namespace: namespace,
+		Name:      name,
+		Phase:     phase,
+		Offset:    offset.Milliseconds(),
+		SentAt:    time.Now(),
+	}
+
+	var err error
+	deadline := request.SentAt.Add(WorkloadRetryTimeout)
+	for {
+		request.Attempts++
+		start := time.Now()
+		err = send(ctx)
+		request.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+		if err == nil || !isTransient(err) || time.Now().Add(WorkloadRetryInterval).After(deadline) || !sleepContext(ctx, WorkloadRetryInterval) {
+			break
+		}
+	}
+	if err != nil {
+		request.Error = err.Error()
+		err = fmt.Errorf("failed to %s %s/%s after %d attempts: %v", verb, namespace, name, request.Attempts, err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.requests = append(w.requests, request)
+
+	return err
+}
+
+// isTransient report whether a failed request may succeed if sent again: the webhook is not serving yet, the
+// API server is overloaded or timed out, the object changed under the request, or the server failed
+func isTransient(err error) bool {
+	if apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) || apierrors.IsTooManyRequests(err) || apierrors.IsConflict(err) {
+		return true
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Code >= http.StatusInternalServerError {
+		return true
+	}
+	return strings.Contains(err.Error(), "connection refused")
+}
+
+// sleepContext sleep for d, returning false if the context was cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
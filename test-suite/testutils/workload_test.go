@@ -0,0 +1,170 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// reactToApply make the fake client create or replace objects on server-side apply patches, which it does not support
+func reactToApply(dynamicClient *dynamicfake.FakeDynamicClient) {
+	dynamicClient.PrependReactor("patch", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		patch := action.(clienttesting.PatchAction)
+		if patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(patch.GetPatch()); err != nil {
+			return true, nil, err
+		}
+		tracker := dynamicClient.Tracker()
+		err := tracker.Create(patch.GetResource(), obj, patch.GetNamespace())
+		if apierrors.IsAlreadyExists(err) {
+			err = tracker.Update(patch.GetResource(), obj, patch.GetNamespace())
+		}
+
+		return true, obj, err
+	})
+}
+
+var _ = Describe("WorkloadDriver", func() {
+	It("should load a sample and apply copies of it", func() {
+		dir, err := os.MkdirTemp("", "samples")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		file := filepath.Join(dir, "cache_v1alpha1_memcached.yaml")
+		Expect(os.WriteFile(file, []byte(`apiVersion: cache.example.com/v1alpha1
+kind: Memcached
+metadata:
+  name: memcached-sample
+spec:
+  size: 1
+`), 0644)).To(Succeed())
+
+		sample, err := LoadSample(file)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sample.GetKind()).To(Equal("Memcached"))
+
+		ctx := context.TODO()
+		dynamicClient := newFakeDynamicClient()
+		reactToApply(dynamicClient)
+		driver := NewWorkloadDriver(dynamicClient, memcachedGVR, NewTimeline(), nil)
+		for _, name := range []string{"memcached-sample00", "memcached-sample01"} {
+			Expect(driver.Create(ctx, NamedCR(sample, name, Namespace))).To(Succeed())
+		}
+
+		cr := NamedCR(sample, "memcached-sample01", Namespace)
+		Expect(unstructured.SetNestedField(cr.Object, int64(3), "spec", "size")).To(Succeed())
+		Expect(driver.Apply(ctx, cr)).To(Succeed())
+		Expect(driver.Delete(ctx, Namespace, "memcached-sample00")).To(Succeed())
+
+		crs, err := dynamicClient.Resource(memcachedGVR).Namespace(Namespace).List(ctx, metav1.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(crs.Items).To(HaveLen(1))
+		size, _, err := unstructured.NestedInt64(crs.Items[0].Object, "spec", "size")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(int64(3)))
+
+		requests := driver.Requests()
+		Expect(requests).To(HaveLen(4))
+		for _, request := range requests {
+			Expect(request.Phase).To(Equal(PhaseBaseline))
+			Expect(request.Attempts).To(Equal(1))
+			Expect(request.Error).To(BeEmpty())
+		}
+		Expect(requests[3].Verb).To(Equal(WorkloadDelete))
+		Expect(driver.Latencies()).To(HaveKeyWithValue(WorkloadApply, HaveField("Count", 3)))
+	})
+
+	It("should record the lifecycle of the CRs it creates and deletes", func() {
+		dynamicClient := newFakeDynamicClient()
+		reactToApply(dynamicClient)
+		timeline := NewTimeline()
+		tracker := NewLifecycleTracker(nil, dynamicClient, memcachedGVR, StatusNodesReady, timeline, Namespace)
+		driver := NewWorkloadDriver(dynamicClient, memcachedGVR, timeline, tracker)
+
+		Expect(driver.Create(context.TODO(), fakeMemcached("memcached-sample00", ""))).To(Succeed())
+		Expect(driver.Delete(context.TODO(), Namespace, "memcached-sample00")).To(Succeed())
+
+		lifecycles := tracker.Lifecycles()
+		Expect(lifecycles).To(HaveLen(1))
+		Expect(lifecycles[0].Requested).NotTo(BeNil())
+		Expect(lifecycles[0].DeleteRequested).NotTo(BeNil())
+	})
+
+	It("should not track CRs it failed to create", func() {
+		dynamicClient := newFakeDynamicClient()
+		dynamicClient.PrependReactor("patch", "*", func(clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(memcachedGVR.GroupResource(), "memcached-sample00", errors.New("denied"))
+		})
+		tracker := NewLifecycleTracker(nil, dynamicClient, memcachedGVR, StatusNodesReady, NewTimeline(), Namespace)
+		driver := NewWorkloadDriver(dynamicClient, memcachedGVR, NewTimeline(), tracker)
+
+		Expect(driver.Create(context.TODO(), fakeMemcached("memcached-sample00", ""))).NotTo(Succeed())
+		Expect(tracker.Lifecycles()).To(BeEmpty())
+	})
+
+	It("should give up once the context is done", func() {
+		dynamicClient := newFakeDynamicClient()
+		dynamicClient.PrependReactor("delete", "*", func(clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("dial tcp 10.96.0.1:443: connect: connection refused")
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		driver := NewWorkloadDriver(dynamicClient, memcachedGVR, NewTimeline(), nil)
+
+		Expect(driver.Delete(ctx, Namespace, "memcached-sample00")).To(MatchError(ContainSubstring("connection refused")))
+		requests := driver.Requests()
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Attempts).To(Equal(1))
+		Expect(requests[0].Error).To(ContainSubstring("connection refused"))
+		Expect(driver.Latencies()).To(BeEmpty())
+	})
+
+	It("should retry transient errors until the request succeeds", func() {
+		dynamicClient := newFakeDynamicClient()
+		reactToApply(dynamicClient)
+		failures := 1
+		dynamicClient.PrependReactor("patch", "*", func(clienttesting.Action) (bool, runtime.Object, error) {
+			if failures == 0 {
+				return false, nil, nil
+			}
+			failures--
+			return true, nil, apierrors.NewInternalError(errors.New("failed calling webhook: connection refused"))
+		})
+		driver := NewWorkloadDriver(dynamicClient, memcachedGVR, NewTimeline(), nil)
+
+		Expect(driver.Apply(context.TODO(), fakeMemcached("memcached-sample00", ""))).To(Succeed())
+		requests := driver.Requests()
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Attempts).To(Equal(2))
+		Expect(requests[0].Error).To(BeEmpty())
+	})
+
+	It("should not retry errors that would fail again", func() {
+		dynamicClient := newFakeDynamicClient()
+		dynamicClient.PrependReactor("delete", "*", func(clienttesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(memcachedGVR.GroupResource(), "memcached-sample00", errors.New("denied"))
+		})
+		driver := NewWorkloadDriver(dynamicClient, memcachedGVR, NewTimeline(), nil)
+
+		start := time.Now()
+		err := driver.Delete(context.TODO(), Namespace, "memcached-sample00")
+		Expect(err).To(MatchError(ContainSubstring("after 1 attempts")))
+		Expect(time.Since(start)).To(BeNumerically("<", WorkloadRetryInterval))
+		Expect(driver.Requests()[0].Attempts).To(Equal(1))
+	})
+})